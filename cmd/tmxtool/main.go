@@ -0,0 +1,88 @@
+// Command tmxtool works with TMX map files.
+//
+// Usage:
+//
+//	tmxtool diff old.tmx new.tmx
+//
+// The diff command prints the changes between two maps: changed tiles per
+// layer with their coordinates and old and new global tile IDs, added,
+// removed and moved objects by ID, and changed properties and tilesets. Like
+// diff(1), it exits with status 1 if the maps differ and 2 on errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/elliotmr/tmx"
+	"github.com/pkg/errors"
+)
+
+// errUsage is returned by commands called with invalid arguments.
+var errUsage = errors.New("invalid arguments")
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: tmxtool diff old.tmx new.tmx")
+	flag.PrintDefaults()
+}
+
+func loadMap(path string) (*tmx.Map, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open map")
+	}
+	defer fp.Close()
+	m, err := tmx.Load(fp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to load map '%s'", path)
+	}
+	return m, nil
+}
+
+func diff(args []string) (bool, error) {
+	if len(args) != 2 {
+		return false, errUsage
+	}
+	a, err := loadMap(args[0])
+	if err != nil {
+		return false, err
+	}
+	b, err := loadMap(args[1])
+	if err != nil {
+		return false, err
+	}
+	d, err := tmx.Diff(a, b)
+	if err != nil {
+		return false, err
+	}
+	fmt.Print(d)
+	return !d.Empty(), nil
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	switch flag.Arg(0) {
+	case "diff":
+		changed, err := diff(flag.Args()[1:])
+		if err == errUsage {
+			usage()
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "tmxtool:", err)
+			os.Exit(2)
+		}
+		if changed {
+			os.Exit(1)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,62 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Color is a non-alpha-premultiplied color as written in TMX files in the
+// #AARRGGBB or #RRGGBB format. It implements color.Color.
+type Color color.NRGBA
+
+// ParseColor parses a color in the #AARRGGBB or #RRGGBB format, colors
+// without alpha are opaque. The legacy form without the leading '#' is also
+// accepted.
+func ParseColor(s string) (Color, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return Color{}, errors.Errorf("invalid color: %s", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Color{}, errors.Wrapf(err, "invalid color: %s", s)
+	}
+	c := Color{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xFF}
+	if len(hex) == 8 {
+		c.A = uint8(v >> 24)
+	}
+	return c, nil
+}
+
+// RGBA implements color.Color.
+func (c Color) RGBA() (r, g, b, a uint32) {
+	return color.NRGBA(c).RGBA()
+}
+
+// String formats the color as #RRGGBB, or #AARRGGBB if it is not opaque.
+func (c Color) String() string {
+	if c.A == 0xFF {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.A, c.R, c.G, c.B)
+}
+
+// MarshalXMLAttr implements xml.MarshalerAttr.
+func (c Color) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	return xml.Attr{Name: name, Value: c.String()}, nil
+}
+
+// UnmarshalXMLAttr implements xml.UnmarshalerAttr.
+func (c *Color) UnmarshalXMLAttr(attr xml.Attr) error {
+	parsed, err := ParseColor(attr.Value)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
@@ -0,0 +1,50 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#ff8000")
+	require.NoError(t, err)
+	assert.Equal(t, Color{R: 0xFF, G: 0x80, B: 0x00, A: 0xFF}, c)
+	assert.Equal(t, "#ff8000", c.String())
+
+	c, err = ParseColor("#80102030")
+	require.NoError(t, err)
+	assert.Equal(t, Color{R: 0x10, G: 0x20, B: 0x30, A: 0x80}, c)
+	assert.Equal(t, "#80102030", c.String())
+
+	c, err = ParseColor("A0A0A4")
+	require.NoError(t, err)
+	assert.Equal(t, Color{R: 0xA0, G: 0xA0, B: 0xA4, A: 0xFF}, c)
+
+	_, err = ParseColor("#fff")
+	assert.Error(t, err)
+	_, err = ParseColor("#gg0000")
+	assert.Error(t, err)
+}
+
+func TestColorXML(t *testing.T) {
+	l := &Layer{}
+	require.NoError(t, xml.Unmarshal([]byte(`<layer name="a" color="#a0a0a4" tintcolor="#7fff0000"/>`), l))
+	assert.Equal(t, Color{R: 0xA0, G: 0xA0, B: 0xA4, A: 0xFF}, *l.Color)
+	assert.Equal(t, Color{R: 0xFF, A: 0x7F}, *l.TintColor)
+
+	out, err := xml.Marshal(l)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `color="#a0a0a4"`)
+	assert.Contains(t, string(out), `tintcolor="#7fff0000"`)
+
+	l = &Layer{}
+	require.NoError(t, xml.Unmarshal([]byte(`<layer name="a"/>`), l))
+	out, err = xml.Marshal(l)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "color")
+
+	assert.Error(t, xml.Unmarshal([]byte(`<layer name="a" tintcolor="red"/>`), l))
+}
@@ -0,0 +1,215 @@
+package tmx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Encode replaces the tiles of fixed-size map data, using the current
+// encoding and compression of the data. The width of the layer is required
+// to break csv data into rows.
+func (d *Data) Encode(width int, tiles []TileInstance) error {
+	td, raw, err := encodeTileData(d.Encoding, d.Compression, width, tiles)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode layer data")
+	}
+	d.TileData, d.Data = td, raw
+	d.tiles = nil
+	return nil
+}
+
+// Flush encodes the tiles changed by SetTile and SetRegion into TileData or
+// Data. It is called when the data is marshalled, the methods reading the
+// tiles see the changes without it. Flush is only needed before accessing
+// TileData or Data directly.
+func (d *Data) Flush() error {
+	if d.tiles == nil {
+		return nil
+	}
+	return d.Encode(d.width, d.tiles)
+}
+
+// decoded returns the tiles of fixed-size map data, which SetTile and
+// SetRegion change in place until the data is flushed.
+func (d *Data) decoded(width int) ([]TileInstance, error) {
+	if d.tiles == nil {
+		tiles, err := d.Tiles()
+		if err != nil {
+			return nil, err
+		}
+		d.tiles = tiles
+	}
+	d.width = width
+	return d.tiles, nil
+}
+
+// SetTile changes the tile at position (x, y) in tiles. Fixed-size map data
+// is decoded on the first change and only encoded again by Flush, so single
+// tiles can be changed cheaply. For infinite maps the chunk containing the
+// tile is re-encoded, a new chunk is added if there is none.
+func (d *Data) SetTile(width, x, y int, tile TileInstance) error {
+	if len(d.Chunks) == 0 {
+		tiles, err := d.decoded(width)
+		if err != nil {
+			return err
+		}
+		i := y*width + x
+		if x < 0 || x >= width || y < 0 || i >= len(tiles) {
+			return errors.Errorf("tile (%d, %d) out of bounds", x, y)
+		}
+		tiles[i] = tile
+		return nil
+	}
+
+	c := d.chunkAt(x, y)
+	iter, err := d.ChunkIter(c)
+	if err != nil {
+		return errors.Wrap(err, "unable to load chunk iterator")
+	}
+	tiles := make([]TileInstance, c.Width*c.Height)
+	for iter.Next() {
+		if i := int(iter.GetIndex()); i < len(tiles) {
+			tiles[i] = iter.Get()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return errors.Wrapf(err, "unable to iterate through chunk (%v, %v)", c.X, c.Y)
+	}
+	tiles[(y-int(c.Y))*c.Width+(x-int(c.X))] = tile
+	c.TileData, c.Data, err = encodeTileData(d.Encoding, d.Compression, c.Width, tiles)
+	return errors.Wrapf(err, "unable to encode chunk (%v, %v)", c.X, c.Y)
+}
+
+// Region returns the w x h tiles starting at position (x, y) in tiles, row
+// by row. Positions outside of the data are returned as empty tiles.
+func (d *Data) Region(width, x, y, w, h int) ([]TileInstance, error) {
+	region := make([]TileInstance, w*h)
+	err := d.ForEach(width, func(tx, ty int, tile TileInstance) {
+		if tx >= x && tx < x+w && ty >= y && ty < y+h {
+			region[(ty-y)*w+(tx-x)] = tile
+		}
+	})
+	return region, err
+}
+
+// SetRegion changes the tiles of a rectangle w tiles wide starting at
+// position (x, y), the tiles are given row by row. Like with SetTile,
+// fixed-size map data is only encoded again by Flush. Nothing is changed if
+// a tile is out of bounds.
+func (d *Data) SetRegion(width, x, y, w int, tiles []TileInstance) error {
+	if w <= 0 {
+		return errors.New("invalid region width")
+	}
+	if len(d.Chunks) > 0 {
+		for i, tile := range tiles {
+			err := d.SetTile(width, x+i%w, y+i/w, tile)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	all, err := d.decoded(width)
+	if err != nil {
+		return err
+	}
+	for i := range tiles {
+		tx, ty := x+i%w, y+i/w
+		if tx < 0 || tx >= width || ty < 0 || ty*width+tx >= len(all) {
+			return errors.Errorf("tile (%d, %d) out of bounds", tx, ty)
+		}
+	}
+	for i, tile := range tiles {
+		all[(y+i/w)*width+x+i%w] = tile
+	}
+	return nil
+}
+
+// chunkAt returns the chunk containing the tile at (x, y). If there is none,
+// an empty chunk of the size of the first chunk is added, aligned to
+// multiples of its size as Tiled does.
+func (d *Data) chunkAt(x, y int) *Chunk {
+	for i := range d.Chunks {
+		c := &d.Chunks[i]
+		if x >= int(c.X) && x < int(c.X)+c.Width && y >= int(c.Y) && y < int(c.Y)+c.Height {
+			return c
+		}
+	}
+	w, h := d.Chunks[0].Width, d.Chunks[0].Height
+	d.Chunks = append(d.Chunks, Chunk{
+		X:      math.Floor(float64(x)/float64(w)) * float64(w),
+		Y:      math.Floor(float64(y)/float64(h)) * float64(h),
+		Width:  w,
+		Height: h,
+	})
+	return &d.Chunks[len(d.Chunks)-1]
+}
+
+// encodeTileData encodes tiles either as <tile> elements or as the raw
+// contents of a <data> or <chunk> element, in the same layout Tiled writes.
+func encodeTileData(encoding, compression *string, width int, tiles []TileInstance) ([]TileData, []byte, error) {
+	switch {
+	case encoding == nil && compression != nil:
+		return nil, nil, errors.New("compression without encoding is not possible")
+	case encoding == nil:
+		td := make([]TileData, len(tiles))
+		for i, tile := range tiles {
+			td[i].GID = uint32(tile)
+		}
+		return td, nil, nil
+	case *encoding == "csv":
+		var buf bytes.Buffer
+		buf.WriteByte('\n')
+		for i, tile := range tiles {
+			buf.WriteString(strconv.FormatUint(uint64(tile), 10))
+			if i < len(tiles)-1 {
+				buf.WriteByte(',')
+				if width > 0 && (i+1)%width == 0 {
+					buf.WriteByte('\n')
+				}
+			}
+		}
+		buf.WriteByte('\n')
+		return nil, buf.Bytes(), nil
+	case *encoding == "base64":
+		var raw bytes.Buffer
+		var w io.WriteCloser
+		switch {
+		case compression == nil, *compression == "":
+			w = nopWriteCloser{&raw}
+		case *compression == "gzip":
+			w = gzip.NewWriter(&raw)
+		case *compression == "zlib":
+			w = zlib.NewWriter(&raw)
+		default:
+			return nil, nil, errors.Errorf("invalid compression: %s", *compression)
+		}
+		err := binary.Write(w, binary.LittleEndian, tiles)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = w.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, []byte("\n" + base64.StdEncoding.EncodeToString(raw.Bytes()) + "\n"), nil
+	default:
+		return nil, nil, errors.Errorf("invalid encoding: %s", *encoding)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
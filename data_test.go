@@ -0,0 +1,82 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	csv, b64, gz, zl := "csv", "base64", "gzip", "zlib"
+	tiles := []TileInstance{1, 0, 2, 3, TileInstance(FlippedVerticallyFlag | 4), 0}
+	for _, d := range []*Data{
+		{},
+		{Encoding: &csv},
+		{Encoding: &b64},
+		{Encoding: &b64, Compression: &gz},
+		{Encoding: &b64, Compression: &zl},
+	} {
+		require.NoError(t, d.Encode(3, tiles))
+		decoded, err := d.Tiles()
+		require.NoError(t, err)
+		assert.Equal(t, tiles, decoded)
+
+		require.NoError(t, d.SetTile(3, 1, 1, 9))
+		decoded, err = d.Tiles()
+		require.NoError(t, err)
+		assert.EqualValues(t, 9, decoded[4])
+		assert.Error(t, d.SetTile(3, 3, 0, 9))
+		assert.Error(t, d.SetTile(3, 0, 2, 9))
+
+		// changes are only encoded when the data is flushed
+		require.NoError(t, d.Flush())
+		encoded := &Data{Encoding: d.Encoding, Compression: d.Compression, TileData: d.TileData, Data: d.Data}
+		decoded, err = encoded.Tiles()
+		require.NoError(t, err)
+		assert.EqualValues(t, 9, decoded[4])
+	}
+
+	d := &Data{Encoding: &csv}
+	require.NoError(t, d.Encode(3, tiles))
+	require.NoError(t, d.SetRegion(3, 1, 0, 2, []TileInstance{7, 8, 9, 10}))
+	assert.Error(t, d.SetRegion(3, 2, 0, 2, []TileInstance{5, 5}))
+	assert.Equal(t, "\n1,0,2,\n3,1073741828,0\n", string(d.Data))
+	out, err := xml.Marshal(d)
+	require.NoError(t, err)
+	assert.Equal(t, "<Data encoding=\"csv\">\n1,7,8,\n3,9,10\n</Data>", string(out))
+
+	d = &Data{Encoding: &csv}
+	require.NoError(t, d.Encode(3, tiles))
+	assert.Equal(t, "\n1,0,2,\n3,1073741828,0\n", string(d.Data))
+}
+
+func TestSetTileChunks(t *testing.T) {
+	fp, err := os.Open("resources/modern.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	data := m.Layers[0].Data
+	require.NoError(t, data.SetTile(0, -2, 3, 5))
+	require.NoError(t, data.SetTile(0, 20, -1, 6))
+	require.Len(t, data.Chunks, 3)
+	assert.Equal(t, Chunk{X: 16, Y: -16, Width: 16, Height: 16}, Chunk{
+		X: data.Chunks[2].X, Y: data.Chunks[2].Y, Width: data.Chunks[2].Width, Height: data.Chunks[2].Height,
+	})
+
+	tiles := make(map[[2]int]uint32)
+	err = data.ForEach(0, func(x, y int, tile TileInstance) {
+		if tile.GID() != 0 {
+			tiles[[2]int{x, y}] = tile.GID()
+		}
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, tiles[[2]int{-2, 3}])
+	assert.EqualValues(t, 6, tiles[[2]int{20, -1}])
+	assert.EqualValues(t, 1, tiles[[2]int{-16, 0}])
+	assert.Len(t, tiles, 20)
+}
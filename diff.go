@@ -0,0 +1,543 @@
+package tmx
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MapDiff is the semantic difference between two maps as found by Diff.
+type MapDiff struct {
+	Properties []PropertyChange // The changed properties of the map.
+	TileSets   []TileSetChange  // The added, removed and changed tilesets.
+	Layers     []LayerDiff      // The added and removed layers and the layers with changed contents.
+}
+
+// PropertyChange is an added, removed or changed property. Old is nil if
+// the property was added, New is nil if it was removed.
+type PropertyChange struct {
+	Name string
+	Old  *Property
+	New  *Property
+}
+
+// TileSetChange is an added, removed or changed tileset. Old is nil if the
+// tileset was added, New is nil if it was removed. Changes describes the
+// changes of a tileset found in both maps.
+type TileSetChange struct {
+	Old     *TileSet
+	New     *TileSet
+	Changes []string
+}
+
+// LayerDiff is an added, removed or changed layer. Old is nil if the layer
+// was added, New is nil if it was removed. Layers are matched by their ID,
+// or by their name and type if they have none.
+type LayerDiff struct {
+	Old        *Layer
+	New        *Layer
+	Properties []PropertyChange // The changed properties of the layer.
+	Tiles      []TileChange     // The changed tiles of a tile layer, ordered by row.
+	Objects    []ObjectChange   // The added, removed and changed objects of an object group, ordered by ID. Added groups only list the objects moved into them.
+}
+
+// TileChange is a changed tile of a tile layer at (X, Y) in map tiles.
+type TileChange struct {
+	X, Y int
+	Old  TileInstance
+	New  TileInstance
+}
+
+// ObjectChange is an added, removed or changed object. Old is nil if the
+// object was added, New is nil if it was removed. Objects are matched by ID
+// across all object groups, an object moved to another group is a change of
+// the group it is in now. Objects without an ID are only matched within
+// their group, by their order among the objects without an ID.
+type ObjectChange struct {
+	ID         uint32
+	Old        *Object
+	New        *Object
+	OldGroup   *Layer           // The object group the object was in before, if it was moved to another group.
+	Properties []PropertyChange // The changed properties of the object.
+}
+
+// Diff compares two maps and returns the changes that turn a into b. Tiles
+// are compared by their raw value, so a changed flip flag is a change.
+func Diff(a, b *Map) (*MapDiff, error) {
+	d := &MapDiff{
+		Properties: diffProperties(a.Properties, b.Properties),
+		TileSets:   diffTileSets(a.TileSets, b.TileSets),
+	}
+	var err error
+	d.Layers, err = diffLayers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Empty returns true if the maps are the same.
+func (d *MapDiff) Empty() bool {
+	return len(d.Properties) == 0 && len(d.TileSets) == 0 && len(d.Layers) == 0
+}
+
+// String formats the diff with one change per line.
+func (d *MapDiff) String() string {
+	var buf bytes.Buffer
+	for _, pc := range d.Properties {
+		fmt.Fprintf(&buf, "map: %s\n", pc)
+	}
+	for _, tc := range d.TileSets {
+		fmt.Fprintf(&buf, "%s\n", tc)
+	}
+	for _, ld := range d.Layers {
+		buf.WriteString(ld.String())
+	}
+	return buf.String()
+}
+
+// String formats the property change, e.g. `property "speed": "1" -> "2"`.
+func (pc PropertyChange) String() string {
+	switch {
+	case pc.Old == nil:
+		return fmt.Sprintf("property %q added: %s", pc.Name, propertyValue(pc.New))
+	case pc.New == nil:
+		return fmt.Sprintf("property %q removed: %s", pc.Name, propertyValue(pc.Old))
+	}
+	return fmt.Sprintf("property %q: %s -> %s", pc.Name, propertyValue(pc.Old), propertyValue(pc.New))
+}
+
+func propertyValue(p *Property) string {
+	if p.Type != nil && *p.Type != "string" {
+		return fmt.Sprintf("%q (%s)", p.Value, *p.Type)
+	}
+	return fmt.Sprintf("%q", p.Value)
+}
+
+// String formats the tileset change on one line.
+func (tc TileSetChange) String() string {
+	switch {
+	case tc.Old == nil:
+		return fmt.Sprintf("tileset %q added at firstgid %d", tileSetName(tc.New), tc.New.FirstGID)
+	case tc.New == nil:
+		return fmt.Sprintf("tileset %q removed from firstgid %d", tileSetName(tc.Old), tc.Old.FirstGID)
+	}
+	return fmt.Sprintf("tileset %q: %s", tileSetName(tc.New), strings.Join(tc.Changes, ", "))
+}
+
+func tileSetName(ts *TileSet) string {
+	if ts.Name == "" {
+		return ts.Source
+	}
+	return ts.Name
+}
+
+// String formats the layer diff with one change per line.
+func (ld LayerDiff) String() string {
+	var buf bytes.Buffer
+	switch {
+	case ld.Old == nil:
+		fmt.Fprintf(&buf, "%s: added\n", layerName(ld.New))
+		for _, oc := range ld.Objects {
+			fmt.Fprintf(&buf, "%s: %s\n", layerName(ld.New), oc)
+		}
+		return buf.String()
+	case ld.New == nil:
+		fmt.Fprintf(&buf, "%s: removed\n", layerName(ld.Old))
+		return buf.String()
+	}
+	name := layerName(ld.New)
+	if ld.Old.Name != ld.New.Name {
+		fmt.Fprintf(&buf, "%s: renamed from %q\n", name, ld.Old.Name)
+	}
+	for _, pc := range ld.Properties {
+		fmt.Fprintf(&buf, "%s: %s\n", name, pc)
+	}
+	for _, tc := range ld.Tiles {
+		fmt.Fprintf(&buf, "%s: %s\n", name, tc)
+	}
+	for _, oc := range ld.Objects {
+		fmt.Fprintf(&buf, "%s: %s\n", name, oc)
+	}
+	return buf.String()
+}
+
+func layerName(l *Layer) string {
+	if l.ID != 0 {
+		return fmt.Sprintf("%s %q (%d)", l.XMLName.Local, l.Name, l.ID)
+	}
+	return fmt.Sprintf("%s %q", l.XMLName.Local, l.Name)
+}
+
+// String formats the tile change, e.g. "tile (3, 4): 5 -> 7h". The flip
+// flags are appended to the global tile IDs as h, v and d.
+func (tc TileChange) String() string {
+	return fmt.Sprintf("tile (%d, %d): %s -> %s", tc.X, tc.Y, tileString(tc.Old), tileString(tc.New))
+}
+
+func tileString(tile TileInstance) string {
+	s := fmt.Sprint(tile.GID())
+	if tile.FlippedHorizontally() {
+		s += "h"
+	}
+	if tile.FlippedVertically() {
+		s += "v"
+	}
+	if tile.FlippedDiagonally() {
+		s += "d"
+	}
+	return s
+}
+
+// Added returns true if the object is only in the new map.
+func (oc ObjectChange) Added() bool {
+	return oc.Old == nil
+}
+
+// Removed returns true if the object is only in the old map.
+func (oc ObjectChange) Removed() bool {
+	return oc.New == nil
+}
+
+// Moved returns true if the position of the object changed.
+func (oc ObjectChange) Moved() bool {
+	return oc.Old != nil && oc.New != nil && (oc.Old.X != oc.New.X || oc.Old.Y != oc.New.Y)
+}
+
+// Regrouped returns true if the object was moved to another object group.
+func (oc ObjectChange) Regrouped() bool {
+	return oc.OldGroup != nil
+}
+
+// Changed returns true if anything but the position or the object group of
+// the object changed, including its properties.
+func (oc ObjectChange) Changed() bool {
+	return len(oc.Properties) > 0 || oc.changedOther()
+}
+
+// changedOther returns true if anything but the position and the properties
+// of the object changed.
+func (oc ObjectChange) changedOther() bool {
+	if oc.Old == nil || oc.New == nil {
+		return false
+	}
+	a, b := *oc.Old, *oc.New
+	a.X, a.Y, b.X, b.Y = 0, 0, 0, 0
+	a.Properties, b.Properties = nil, nil
+	return !reflect.DeepEqual(a, b)
+}
+
+// String formats the object change on one line, e.g.
+// `object 3 moved (1, 2) -> (3, 4), property "speed": "1" -> "2"`.
+func (oc ObjectChange) String() string {
+	switch {
+	case oc.Added():
+		return fmt.Sprintf("object %d added at (%g, %g)", oc.ID, oc.New.X, oc.New.Y)
+	case oc.Removed():
+		return fmt.Sprintf("object %d removed from (%g, %g)", oc.ID, oc.Old.X, oc.Old.Y)
+	}
+	var changes []string
+	if oc.Regrouped() {
+		changes = append(changes, fmt.Sprintf("moved here from %s", layerName(oc.OldGroup)))
+	}
+	if oc.Moved() {
+		changes = append(changes, fmt.Sprintf("moved (%g, %g) -> (%g, %g)", oc.Old.X, oc.Old.Y, oc.New.X, oc.New.Y))
+	}
+	for _, pc := range oc.Properties {
+		changes = append(changes, pc.String())
+	}
+	if oc.changedOther() {
+		changes = append(changes, "changed")
+	}
+	return fmt.Sprintf("object %d %s", oc.ID, strings.Join(changes, ", "))
+}
+
+// diffProperties returns the changes of the properties, ordered by name.
+func diffProperties(a, b *Properties) []PropertyChange {
+	find := func(props *Properties, name string) *Property {
+		if props == nil {
+			return nil
+		}
+		for i := range props.Properties {
+			if props.Properties[i].Name == name {
+				return &props.Properties[i]
+			}
+		}
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, props := range []*Properties{a, b} {
+		if props != nil {
+			for _, p := range props.Properties {
+				names[p.Name] = true
+			}
+		}
+	}
+	var changes []PropertyChange
+	for name := range names {
+		old, cur := find(a, name), find(b, name)
+		if old == nil || cur == nil || !reflect.DeepEqual(old, cur) {
+			changes = append(changes, PropertyChange{Name: name, Old: old, New: cur})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// diffTileSets matches the tilesets by their source, or by their name if
+// they are embedded.
+func diffTileSets(a, b []*TileSet) []TileSetChange {
+	key := func(ts *TileSet) string {
+		if ts.Source != "" {
+			return "source:" + ts.Source
+		}
+		return "name:" + ts.Name
+	}
+	olds := make(map[string]*TileSet)
+	for _, ts := range a {
+		olds[key(ts)] = ts
+	}
+	var changes []TileSetChange
+	matched := make(map[*TileSet]bool)
+	for _, ts := range b {
+		old := olds[key(ts)]
+		if old == nil || matched[old] {
+			changes = append(changes, TileSetChange{New: ts})
+			continue
+		}
+		matched[old] = true
+		if tc := compareTileSets(old, ts); len(tc.Changes) > 0 {
+			changes = append(changes, tc)
+		}
+	}
+	for _, ts := range a {
+		if !matched[ts] {
+			changes = append(changes, TileSetChange{Old: ts})
+		}
+	}
+	return changes
+}
+
+// compareTileSets describes the changes of a tileset.
+func compareTileSets(a, b *TileSet) TileSetChange {
+	tc := TileSetChange{Old: a, New: b}
+	field := func(name string, old, cur interface{}) {
+		if !reflect.DeepEqual(old, cur) {
+			tc.Changes = append(tc.Changes, fmt.Sprintf("%s %v -> %v", name, old, cur))
+		}
+	}
+	imageSource := func(ts *TileSet) string {
+		if ts.Image == nil {
+			return "none"
+		}
+		if ts.Image.Embedded() {
+			return "embedded"
+		}
+		return ts.Image.Source
+	}
+	field("firstgid", a.FirstGID, b.FirstGID)
+	field("name", a.Name, b.Name)
+	field("tile size", fmt.Sprintf("%dx%d", a.TileWidth, a.TileHeight), fmt.Sprintf("%dx%d", b.TileWidth, b.TileHeight))
+	field("tilecount", a.TileCount, b.TileCount)
+	field("columns", a.Columns, b.Columns)
+	field("image", imageSource(a), imageSource(b))
+	for _, pc := range diffProperties(a.Properties, b.Properties) {
+		tc.Changes = append(tc.Changes, pc.String())
+	}
+	if !reflect.DeepEqual(a.Tiles, b.Tiles) {
+		tc.Changes = append(tc.Changes, "tiles changed")
+	}
+	if !reflect.DeepEqual(otherSettings(a), otherSettings(b)) {
+		tc.Changes = append(tc.Changes, "settings changed")
+	}
+	return tc
+}
+
+// otherSettings returns a copy of a tileset without the fields compared on
+// their own by compareTileSets.
+func otherSettings(ts *TileSet) TileSet {
+	cp := *ts
+	cp.FirstGID, cp.Name, cp.TileWidth, cp.TileHeight, cp.TileCount, cp.Columns = 0, "", 0, 0, 0, 0
+	cp.Image, cp.Properties, cp.Tiles = nil, nil, nil
+	return cp
+}
+
+// placedObject is an object and the object group it belongs to.
+type placedObject struct {
+	obj   *Object
+	group *Layer
+}
+
+// diffLayers matches the layers of both maps and compares their contents.
+func diffLayers(a, b *Map) ([]LayerDiff, error) {
+	var olds, news []*Layer
+	oldObjects := make(map[uint32]placedObject)
+	newIDs := make(map[uint32]bool)
+	a.walkLayers(func(l, parent *Layer) {
+		olds = append(olds, l)
+		for _, obj := range l.Objects {
+			if obj.ID != 0 {
+				oldObjects[obj.ID] = placedObject{obj, l}
+			}
+		}
+	})
+	b.walkLayers(func(l, parent *Layer) {
+		news = append(news, l)
+		for _, obj := range l.Objects {
+			if obj.ID != 0 {
+				newIDs[obj.ID] = true
+			}
+		}
+	})
+	matched := make(map[*Layer]bool)
+	match := func(l *Layer) *Layer {
+		for _, old := range olds {
+			if matched[old] || old.XMLName.Local != l.XMLName.Local {
+				continue
+			}
+			if (l.ID != 0 && old.ID == l.ID) || (l.ID == 0 && old.ID == 0 && old.Name == l.Name) {
+				matched[old] = true
+				return old
+			}
+		}
+		return nil
+	}
+
+	var diffs []LayerDiff
+	for _, l := range news {
+		old := match(l)
+		objects := diffObjects(old, l, oldObjects, newIDs)
+		if old == nil {
+			var moved []ObjectChange
+			for _, oc := range objects {
+				if oc.Regrouped() {
+					moved = append(moved, oc)
+				}
+			}
+			diffs = append(diffs, LayerDiff{New: l, Objects: moved})
+			continue
+		}
+		ld := LayerDiff{
+			Old:        old,
+			New:        l,
+			Properties: diffProperties(old.Properties, l.Properties),
+			Objects:    objects,
+		}
+		if old.Data != nil || l.Data != nil {
+			var err error
+			ld.Tiles, err = diffTiles(old, int(a.Width), l, int(b.Width))
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to compare layer '%s'", l.Name)
+			}
+		}
+		if old.Name != l.Name || len(ld.Properties) > 0 || len(ld.Tiles) > 0 || len(ld.Objects) > 0 {
+			diffs = append(diffs, ld)
+		}
+	}
+	for _, l := range olds {
+		if !matched[l] {
+			diffs = append(diffs, LayerDiff{Old: l})
+		}
+	}
+	return diffs, nil
+}
+
+// layerTiles returns the non-empty tiles of a layer by position.
+func layerTiles(l *Layer, width int) (map[[2]int]TileInstance, error) {
+	tiles := make(map[[2]int]TileInstance)
+	if l.Data == nil {
+		return tiles, nil
+	}
+	if l.Width != nil {
+		width = int(*l.Width)
+	}
+	err := l.Data.ForEach(width, func(x, y int, tile TileInstance) {
+		if tile != 0 {
+			tiles[[2]int{x, y}] = tile
+		}
+	})
+	return tiles, err
+}
+
+// diffTiles returns the changed tiles of two tile layers, ordered by row.
+func diffTiles(a *Layer, widthA int, b *Layer, widthB int) ([]TileChange, error) {
+	olds, err := layerTiles(a, widthA)
+	if err != nil {
+		return nil, err
+	}
+	news, err := layerTiles(b, widthB)
+	if err != nil {
+		return nil, err
+	}
+	var changes []TileChange
+	for pos, tile := range news {
+		if olds[pos] != tile {
+			changes = append(changes, TileChange{X: pos[0], Y: pos[1], Old: olds[pos], New: tile})
+		}
+	}
+	for pos, tile := range olds {
+		if _, exists := news[pos]; !exists {
+			changes = append(changes, TileChange{X: pos[0], Y: pos[1], Old: tile})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Y != changes[j].Y {
+			return changes[i].Y < changes[j].Y
+		}
+		return changes[i].X < changes[j].X
+	})
+	return changes, nil
+}
+
+// diffObjects returns the changes of the objects of an object group, which
+// was matched with the group old of the other map, ordered by ID. Objects
+// are matched by ID with the objects of all groups of the other map. Objects
+// without an ID are matched by their order among the objects without an ID
+// of both groups. Objects of old that are in no group of the new map are
+// removed.
+func diffObjects(old, cur *Layer, olds map[uint32]placedObject, news map[uint32]bool) []ObjectChange {
+	var noID []*Object
+	if old != nil {
+		for _, obj := range old.Objects {
+			if obj.ID == 0 {
+				noID = append(noID, obj)
+			}
+		}
+	}
+	var changes []ObjectChange
+	for _, obj := range cur.Objects {
+		oc := ObjectChange{ID: obj.ID, New: obj}
+		if obj.ID == 0 {
+			if len(noID) > 0 {
+				oc.Old, noID = noID[0], noID[1:]
+			}
+		} else if p, ok := olds[obj.ID]; ok {
+			oc.Old = p.obj
+			if p.group != old {
+				oc.OldGroup = p.group
+			}
+		}
+		if oc.Old != nil {
+			oc.Properties = diffProperties(oc.Old.Properties, obj.Properties)
+		}
+		if oc.Added() || oc.Regrouped() || oc.Moved() || oc.Changed() {
+			changes = append(changes, oc)
+		}
+	}
+	if old != nil {
+		for _, obj := range old.Objects {
+			if obj.ID != 0 && !news[obj.ID] {
+				changes = append(changes, ObjectChange{ID: obj.ID, Old: obj})
+			}
+		}
+	}
+	for _, obj := range noID {
+		changes = append(changes, ObjectChange{Old: obj})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	return changes
+}
@@ -0,0 +1,107 @@
+package tmx
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadDiffMap(t *testing.T) *Map {
+	fp, err := os.Open("resources/modern.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+	return m
+}
+
+func TestDiff(t *testing.T) {
+	a, b := loadDiffMap(t), loadDiffMap(t)
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+	assert.True(t, d.Empty())
+	assert.Empty(t, d.String())
+
+	require.NoError(t, b.Layers[0].Data.SetTile(0, -16, 0, TileInstance(FlippedHorizontallyFlag|2)))
+	require.NoError(t, b.Layers[0].Data.SetTile(0, 15, 15, 0))
+	obj, _ := b.FindObject(1)
+	obj.X = 48
+	obj.Properties = &Properties{Properties: []Property{{Name: "locked", Value: "true"}}}
+	require.NoError(t, b.AddObject(b.Layers[2], &Object{Name: "chest", X: 1, Y: 2}))
+	b.Properties = &Properties{Properties: []Property{{Name: "music", Value: "cave.ogg"}}}
+	require.NoError(t, b.AddTileSet(&TileSet{Name: "extra", TileCount: 4}))
+	b.TileSets[0].TileCount = 30
+	b.TileSets[0].Tiles = append(b.TileSets[0].Tiles, &Tile{ID: 24})
+	require.NoError(t, b.RemoveLayer(b.Layers[3]))
+
+	d, err = Diff(a, b)
+	require.NoError(t, err)
+	assert.False(t, d.Empty())
+	require.Len(t, d.Layers, 3)
+	assert.Equal(t, []TileChange{
+		{X: -16, Y: 0, Old: 1, New: TileInstance(FlippedHorizontallyFlag | 2)},
+		{X: 15, Y: 15, Old: 7},
+	}, d.Layers[0].Tiles)
+	objects := d.Layers[1].Objects
+	require.Len(t, objects, 2)
+	assert.True(t, objects[0].Moved())
+	assert.True(t, objects[0].Changed())
+	assert.Len(t, objects[0].Properties, 1)
+	assert.True(t, objects[1].Added())
+	assert.Nil(t, d.Layers[2].New)
+
+	assert.Equal(t, `map: property "music" added: "cave.ogg"
+tileset "cave": tilecount 25 -> 30, tiles changed
+tileset "extra" added at firstgid 26
+layer "Ground" (1): tile (-16, 0): 1 -> 2h
+layer "Ground" (1): tile (15, 15): 7 -> 0
+objectgroup "Objects" (4): object 1 moved (16, 32) -> (48, 32), property "locked" added: "true"
+objectgroup "Objects" (4): object 2 added at (1, 2)
+layer "Detail" (5): removed
+`, d.String())
+}
+
+func TestDiffRegroupedObject(t *testing.T) {
+	a, b := loadDiffMap(t), loadDiffMap(t)
+	items := NewObjectGroup("Items")
+	require.NoError(t, b.AddLayer(nil, -1, items))
+	obj, group := b.FindObject(1)
+	group.Objects = nil
+	items.Objects = []*Object{obj}
+
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, d.Layers, 1)
+	require.Len(t, d.Layers[0].Objects, 1)
+	oc := d.Layers[0].Objects[0]
+	assert.True(t, oc.Regrouped())
+	assert.False(t, oc.Moved())
+	assert.False(t, oc.Changed())
+	assert.Equal(t, `objectgroup "Items" (6): added
+objectgroup "Items" (6): object 1 moved here from objectgroup "Objects" (4)
+`, d.String())
+}
+
+func TestDiffObjectsWithoutID(t *testing.T) {
+	a, b := loadDiffMap(t), loadDiffMap(t)
+	a.Layers[2].Objects = append(a.Layers[2].Objects, &Object{Name: "a", X: 1}, &Object{Name: "b", X: 2})
+	b.Layers[2].Objects = append(b.Layers[2].Objects, &Object{Name: "a", X: 1}, &Object{Name: "b", X: 5})
+
+	d, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, d.Layers, 1)
+	require.Len(t, d.Layers[0].Objects, 1)
+	oc := d.Layers[0].Objects[0]
+	assert.True(t, oc.Moved())
+	assert.Equal(t, "b", oc.Old.Name)
+
+	b.Layers[2].Objects = b.Layers[2].Objects[:len(b.Layers[2].Objects)-1]
+	d, err = Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, d.Layers, 1)
+	require.Len(t, d.Layers[0].Objects, 1)
+	assert.True(t, d.Layers[0].Objects[0].Removed())
+	assert.Equal(t, "b", d.Layers[0].Objects[0].Old.Name)
+}
@@ -1,40 +1,149 @@
 package ebitentmx
 
 import (
+	"fmt"
+	"image"
 	"math"
+	"math/rand"
 	"time"
 
+	"github.com/elliotmr/tmx"
 	"github.com/hajimehoshi/ebiten"
-	"fmt"
 )
 
+// NewCamera creates a camera centered on the origin of the map with a zoom
+// of 1. The viewport size is taken from the destination image when drawing,
+// or can be set with SetViewport.
 func NewCamera() *Camera {
 	return &Camera{
+		Zoom:    1.0,
 		updated: time.Now(),
-		opts: &ebiten.DrawImageOptions{
-			GeoM: ebiten.GeoM{},
-			ColorM: ebiten.ColorM{},
-			Filter: ebiten.FilterNearest,
-		},
 	}
 }
 
+// Camera looks at a map through a viewport. All movement is scaled by the
+// time between frames, so it does not depend on the frame rate. A frame
+// should start with StartUpdate, followed by any movement and then DrawMap.
 type Camera struct {
+	X        float64 // The x coordinate the camera is centered on in map pixels.
+	Y        float64 // The y coordinate the camera is centered on in map pixels.
+	Zoom     float64 // The scale of the map on the screen.
+	Rotation float64 // The rotation of the view around the center of the viewport in radians.
+
+	// PixelPerfect rounds the zoom down to a whole number (at least 1) and
+	// aligns the map to whole screen pixels, which keeps pixel art crisp.
+	PixelPerfect bool
+
+	// DeadzoneWidth and DeadzoneHeight give the size in map pixels of the
+	// area around the center of the view in which a followed target can move
+	// without moving the camera.
+	DeadzoneWidth  float64
+	DeadzoneHeight float64
+
+	// Smoothing is the rate per second at which the camera catches up with a
+	// followed target, the camera covers 1-e^(-Smoothing*t) of the distance
+	// after t seconds. Zero moves the camera instantly.
+	Smoothing float64
+
 	updated time.Time
 	DT      time.Duration
 
-	opts       *ebiten.DrawImageOptions
+	width, height int
+
+	bounded bool
+	minX    float64
+	minY    float64
+	maxX    float64
+	maxY    float64
+
+	shakeIntensity float64
+	shakeDuration  time.Duration
+	shakeLeft      time.Duration
+	shakeX         float64
+	shakeY         float64
 }
 
+// StartUpdate starts a new frame, it measures the time since the previous
+// frame and advances the screen shake.
 func (c *Camera) StartUpdate(now time.Time) {
 	c.DT = now.Sub(c.updated)
+	c.updated = now
+	if c.shakeLeft > 0 {
+		c.shakeLeft -= c.DT
+		if c.shakeLeft < 0 {
+			c.shakeLeft = 0
+		}
+		// the shake fades out linearly over its duration
+		amplitude := c.shakeIntensity * float64(c.shakeLeft) / float64(c.shakeDuration)
+		c.shakeX = (rand.Float64()*2 - 1) * amplitude
+		c.shakeY = (rand.Float64()*2 - 1) * amplitude
+	} else {
+		c.shakeX, c.shakeY = 0, 0
+	}
 }
 
-func (c *Camera) Draw(src, dest *ebiten.Image) error {
-	c.updated = c.updated.Add(c.DT)
-	return dest.DrawImage(src, c.opts)
+// SetViewport sets the size of the viewport in screen pixels.
+func (c *Camera) SetViewport(width, height int) {
+	c.width, c.height = width, height
+	c.clamp()
+}
+
+// ClampToMap keeps the view inside the pixel bounds of the map. If the map
+// is smaller than the view, the map is centered.
+func (c *Camera) ClampToMap(m *tmx.Map) {
+	c.ClampTo(0, 0, float64(m.Width*m.TileWidth), float64(m.Height*m.TileHeight))
+}
+
+// ClampTo keeps the view inside the rectangle given in map pixels.
+func (c *Camera) ClampTo(minX, minY, maxX, maxY float64) {
+	c.bounded = true
+	c.minX, c.minY, c.maxX, c.maxY = minX, minY, maxX, maxY
+	c.clamp()
+}
+
+// Unclamp removes the bounds set by ClampTo or ClampToMap.
+func (c *Camera) Unclamp() {
+	c.bounded = false
 }
 
+func (c *Camera) clamp() {
+	if !c.bounded {
+		return
+	}
+	c.X = clampAxis(c.X, c.minX, c.maxX, float64(c.width)/c.scale())
+	c.Y = clampAxis(c.Y, c.minY, c.maxY, float64(c.height)/c.scale())
+}
+
+// clampAxis clamps the center v of a view of the given size to [min, max].
+func clampAxis(v, min, max, size float64) float64 {
+	if max-min <= size {
+		return (min + max) / 2
+	}
+	return math.Max(min+size/2, math.Min(max-size/2, v))
+}
+
+// scale returns the zoom used for drawing.
+func (c *Camera) scale() float64 {
+	if c.PixelPerfect {
+		return math.Max(1, math.Floor(c.Zoom))
+	}
+	return c.Zoom
+}
+
+// MoveTo centers the camera on (x, y) in map pixels.
+func (c *Camera) MoveTo(x, y float64) {
+	c.X, c.Y = x, y
+	c.clamp()
+}
+
+// Move moves the camera by (dx, dy) map pixels.
+func (c *Camera) Move(dx, dy float64) {
+	c.MoveTo(c.X+dx, c.Y+dy)
+}
+
+// Pan moves the camera in a direction at rate map pixels per second. The
+// direction is an angle in radians, 0 pans up, π/2 left, π down and 3π/2
+// right.
 func (c *Camera) Pan(dir float64, rate float64) {
 	var sin, cos float64
 	switch dir {
@@ -49,13 +158,136 @@ func (c *Camera) Pan(dir float64, rate float64) {
 	default:
 		sin, cos = math.Sincos(dir)
 	}
-	c.opts.GeoM.Translate(sin * rate * 0.001 / c.DT.Seconds(), cos * rate * 0.001 / c.DT.Seconds())
+	d := rate * c.DT.Seconds()
+	c.Move(-sin*d, -cos*d)
 }
 
-func (c *Camera) String() string {
-	return fmt.Sprintf(
-		"[x: %0.2f, y: %0.2f]",
-		c.opts.GeoM.Element(0, 2),
-		c.opts.GeoM.Element(1, 2),
+// ZoomAt multiplies the zoom by factor, keeping the map point under the
+// screen position (sx, sy) in place.
+func (c *Camera) ZoomAt(factor, sx, sy float64) {
+	wx, wy := c.ScreenToWorld(sx, sy)
+	c.Zoom *= factor
+	nx, ny := c.ScreenToWorld(sx, sy)
+	c.Move(wx-nx, wy-ny)
+}
+
+// Follow moves the camera towards the target (x, y) in map pixels. The
+// camera only moves once the target leaves the deadzone, and catches up
+// according to Smoothing.
+func (c *Camera) Follow(x, y float64) {
+	dx := deadzoneDistance(x-c.X, c.DeadzoneWidth/2)
+	dy := deadzoneDistance(y-c.Y, c.DeadzoneHeight/2)
+	if c.Smoothing > 0 {
+		f := 1 - math.Exp(-c.Smoothing*c.DT.Seconds())
+		dx, dy = dx*f, dy*f
+	}
+	c.Move(dx, dy)
+}
+
+// deadzoneDistance returns how far d lies outside of [-half, half].
+func deadzoneDistance(d, half float64) float64 {
+	switch {
+	case d > half:
+		return d - half
+	case d < -half:
+		return d + half
+	}
+	return 0
+}
+
+// Shake shakes the view by up to intensity map pixels, fading out over the
+// duration.
+func (c *Camera) Shake(intensity float64, duration time.Duration) {
+	c.shakeIntensity = intensity
+	c.shakeDuration = duration
+	c.shakeLeft = duration
+}
+
+// GeoM returns the transformation from map pixels to screen pixels.
+func (c *Camera) GeoM() ebiten.GeoM {
+	scale := c.scale()
+	geom := ebiten.GeoM{}
+	geom.Translate(-(c.X + c.shakeX), -(c.Y + c.shakeY))
+	geom.Rotate(-c.Rotation)
+	geom.Scale(scale, scale)
+	if c.PixelPerfect && c.Rotation == 0 {
+		// align the map to whole screen pixels
+		geom.Reset()
+		geom.Scale(scale, scale)
+		geom.Translate(
+			math.Round(-(c.X+c.shakeX)*scale),
+			math.Round(-(c.Y+c.shakeY)*scale),
+		)
+	}
+	geom.Translate(math.Floor(float64(c.width)/2), math.Floor(float64(c.height)/2))
+	return geom
+}
+
+// View returns the view the camera draws maps with, its bounds cover the
+// viewport.
+func (c *Camera) View() View {
+	return View{GeoM: c.GeoM(), CameraX: c.X, CameraY: c.Y, Bounds: c.Bounds()}
+}
+
+// Bounds returns the area of the map visible in the viewport in map pixels.
+// With rotation this is the bounding box of the rotated viewport.
+func (c *Camera) Bounds() image.Rectangle {
+	geom := c.GeoM()
+	if !geom.IsInvertible() {
+		return image.Rectangle{}
+	}
+	geom.Invert()
+	w, h := float64(c.width), float64(c.height)
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}} {
+		x, y := geom.Apply(corner[0], corner[1])
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	return image.Rect(
+		int(math.Floor(minX)), int(math.Floor(minY)),
+		int(math.Ceil(maxX)), int(math.Ceil(maxY)),
 	)
+}
+
+// WorldToScreen converts a position in map pixels to screen pixels.
+func (c *Camera) WorldToScreen(x, y float64) (float64, float64) {
+	geom := c.GeoM()
+	return geom.Apply(x, y)
+}
+
+// ScreenToWorld converts a position in screen pixels to map pixels.
+func (c *Camera) ScreenToWorld(x, y float64) (float64, float64) {
+	geom := c.GeoM()
+	geom.Invert()
+	return geom.Apply(x, y)
+}
+
+// Draw draws an image placed at the origin of the map, such as a
+// pre-rendered background, to dest as seen by the camera.
+func (c *Camera) Draw(src, dest *ebiten.Image) error {
+	c.viewport(dest)
+	return dest.DrawImage(src, &ebiten.DrawImageOptions{
+		GeoM:   c.GeoM(),
+		Filter: ebiten.FilterNearest,
+	})
+}
+
+// DrawMap draws the map of the drawer to dest as seen by the camera, layers
+// with parallax factors are moved relative to the camera.
+func (c *Camera) DrawMap(d Drawer, dest *ebiten.Image) error {
+	c.viewport(dest)
+	return d.Draw(dest, c.View())
+}
+
+// viewport takes the viewport size from the destination image, the camera
+// is clamped again as the zoom may have changed.
+func (c *Camera) viewport(dest *ebiten.Image) {
+	c.width, c.height = dest.Size()
+	c.clamp()
+}
+
+func (c *Camera) String() string {
+	return fmt.Sprintf("[x: %0.2f, y: %0.2f, zoom: %0.2f]", c.X, c.Y, c.Zoom)
 }
\ No newline at end of file
@@ -1,8 +1,11 @@
 package ebitentmx
 
 import (
-	"testing"
 	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func BenchmarkSwap(b *testing.B) {
@@ -42,3 +45,118 @@ func BenchmarkSinCos45(b *testing.B) {
 	}
 }
 
+func newTestCamera() *Camera {
+	c := NewCamera()
+	c.SetViewport(200, 100)
+	c.DT = 100 * time.Millisecond
+	return c
+}
+
+func TestCameraScreenToWorld(t *testing.T) {
+	c := newTestCamera()
+	c.MoveTo(50, 40)
+	x, y := c.WorldToScreen(50, 40)
+	assert.InDelta(t, 100, x, 1e-9)
+	assert.InDelta(t, 50, y, 1e-9)
+
+	c.Zoom = 2
+	c.Rotation = math.Pi / 2
+	x, y = c.WorldToScreen(60, 40)
+	assert.InDelta(t, 100, x, 1e-9)
+	assert.InDelta(t, 30, y, 1e-9)
+	wx, wy := c.ScreenToWorld(x, y)
+	assert.InDelta(t, 60, wx, 1e-9)
+	assert.InDelta(t, 40, wy, 1e-9)
+}
+
+func TestCameraZoomAt(t *testing.T) {
+	c := newTestCamera()
+	c.MoveTo(100, 50)
+	wx, wy := c.ScreenToWorld(20, 10)
+	c.ZoomAt(2, 20, 10)
+	x, y := c.WorldToScreen(wx, wy)
+	assert.InDelta(t, 20, x, 1e-9)
+	assert.InDelta(t, 10, y, 1e-9)
+	assert.Equal(t, 2.0, c.Zoom)
+}
+
+func TestCameraPan(t *testing.T) {
+	c := newTestCamera()
+	c.Pan(3*math.Pi/2, 100)
+	assert.InDelta(t, 10, c.X, 1e-9)
+	c.Pan(math.Pi, 100)
+	assert.InDelta(t, 10, c.Y, 1e-9)
+
+	// the distance only depends on the elapsed time
+	c = newTestCamera()
+	c.DT = 50 * time.Millisecond
+	c.Pan(3*math.Pi/2, 100)
+	c.Pan(3*math.Pi/2, 100)
+	assert.InDelta(t, 10, c.X, 1e-9)
+}
+
+func TestCameraClamp(t *testing.T) {
+	c := newTestCamera()
+	c.ClampTo(0, 0, 1000, 500)
+	assert.Equal(t, []float64{100, 50}, []float64{c.X, c.Y})
+	c.MoveTo(990, 490)
+	assert.Equal(t, []float64{900, 450}, []float64{c.X, c.Y})
+
+	// a map smaller than the view is centered
+	c.Zoom = 0.1
+	c.MoveTo(0, 0)
+	assert.Equal(t, []float64{500, 250}, []float64{c.X, c.Y})
+
+	c.Unclamp()
+	c.MoveTo(-10, -10)
+	assert.Equal(t, []float64{-10, -10}, []float64{c.X, c.Y})
+}
+
+func TestCameraFollow(t *testing.T) {
+	c := newTestCamera()
+	c.DeadzoneWidth = 20
+	c.DeadzoneHeight = 20
+	c.Follow(5, -8)
+	assert.Equal(t, []float64{0, 0}, []float64{c.X, c.Y}, "inside the deadzone")
+	c.Follow(30, -8)
+	assert.Equal(t, []float64{20, 0}, []float64{c.X, c.Y})
+
+	// smoothing covers the same distance for the same time at any frame rate
+	slow := newTestCamera()
+	slow.Smoothing = 5
+	slow.Follow(100, 0)
+	fast := newTestCamera()
+	fast.Smoothing = 5
+	fast.DT = 10 * time.Millisecond
+	for i := 0; i < 10; i++ {
+		fast.Follow(100, 0)
+	}
+	assert.InDelta(t, slow.X, fast.X, 1e-9)
+	assert.InDelta(t, 100*(1-math.Exp(-0.5)), slow.X, 1e-9)
+}
+
+func TestCameraPixelPerfect(t *testing.T) {
+	c := newTestCamera()
+	c.PixelPerfect = true
+	c.Zoom = 2.7
+	c.MoveTo(10.3, 20.6)
+	geom := c.GeoM()
+	assert.Equal(t, 2.0, geom.Element(0, 0))
+	x, y := geom.Apply(0, 0)
+	assert.Equal(t, x, math.Round(x))
+	assert.Equal(t, y, math.Round(y))
+}
+
+func TestCameraShake(t *testing.T) {
+	c := newTestCamera()
+	start := time.Now()
+	c.updated = start
+	c.Shake(5, time.Second)
+	c.StartUpdate(start.Add(100 * time.Millisecond))
+	x, y := c.WorldToScreen(0, 0)
+	assert.InDelta(t, 100, x, 4.5)
+	assert.InDelta(t, 50, y, 4.5)
+	c.StartUpdate(start.Add(2 * time.Second))
+	x, y = c.WorldToScreen(0, 0)
+	assert.Equal(t, []float64{100, 50}, []float64{x, y})
+}
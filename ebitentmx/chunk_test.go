@@ -0,0 +1,42 @@
+package ebitentmx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkCacheEviction(t *testing.T) {
+	a, b := &tileLayerDrawer{}, &tileLayerDrawer{}
+	cc := newChunkCache()
+	cc.put(&renderChunk{key: chunkKey{a, 0, 0}, size: 40}, 100)
+	cc.put(&renderChunk{key: chunkKey{a, 1, 0}, size: 40}, 100)
+	_, exists := cc.get(chunkKey{a, 0, 0}) // (1, 0) is now the least recently used
+	assert.True(t, exists)
+
+	cc.put(&renderChunk{key: chunkKey{b, 0, 0}, size: 40}, 100)
+	_, exists = cc.get(chunkKey{a, 1, 0})
+	assert.False(t, exists)
+	_, exists = cc.get(chunkKey{a, 0, 0})
+	assert.True(t, exists)
+	assert.Equal(t, 80, cc.used)
+
+	// a chunk larger than the budget is kept until the next one is added
+	cc.put(&renderChunk{key: chunkKey{b, 1, 0}, size: 200}, 100)
+	assert.Equal(t, 1, cc.lru.Len())
+	assert.Equal(t, 200, cc.used)
+
+	cc.put(&renderChunk{key: chunkKey{a, 2, 0}, size: 10}, 100)
+	cc.removeLayer(a)
+	assert.Equal(t, 0, cc.lru.Len())
+	assert.Empty(t, cc.chunks)
+	assert.Equal(t, 0, cc.used)
+}
+
+func TestFloorDiv(t *testing.T) {
+	assert.Equal(t, 0, floorDiv(31, 32))
+	assert.Equal(t, 1, floorDiv(32, 32))
+	assert.Equal(t, -1, floorDiv(-1, 32))
+	assert.Equal(t, -1, floorDiv(-32, 32))
+	assert.Equal(t, -2, floorDiv(-33, 32))
+}
@@ -1,6 +1,9 @@
 package ebitentmx
 
 import (
+	"image"
+	"math"
+
 	"github.com/hajimehoshi/ebiten"
 	"github.com/pkg/errors"
 	"github.com/elliotmr/tmx"
@@ -18,10 +21,64 @@ type Drawer interface {
 	Type() int
 	Info() *LayerInfo
 	Update() error
-	Draw(image *ebiten.Image) error
-	Image() *ebiten.Image
+	Draw(image *ebiten.Image, view View) error
+}
+
+// TileLayer is implemented by the drawers of tile layers. SetTile changes
+// the tile at position (x, y) in map tiles in both the layer data and the
+// drawer, without updating the whole layer.
+type TileLayer interface {
+	Drawer
+	SetTile(x, y int, tile tmx.TileInstance) error
 }
 
+// FindDrawer returns the drawer of the layer with the given ID within the
+// drawer tree, or nil if there is none.
+func FindDrawer(root Drawer, id uint32) Drawer {
+	if root.Info().ID() == id {
+		return root
+	}
+	if gd, ok := root.(*groupDrawer); ok {
+		for _, child := range gd.children {
+			if d := FindDrawer(child, id); d != nil {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// View describes how the map is drawn to the destination image. Layers with
+// a parallax factor other than 1 are moved relative to the camera. Only the
+// tiles and objects intersecting the visible bounds are drawn.
+type View struct {
+	GeoM    ebiten.GeoM     // Transformation from map pixels to the destination image.
+	CameraX float64         // The x coordinate the camera is centered on in map pixels.
+	CameraY float64         // The y coordinate the camera is centered on in map pixels.
+	Bounds  image.Rectangle // The visible area in map pixels, the empty rectangle draws everything.
+}
+
+// visible returns the visible area in the coordinates of the layer, which
+// are moved by the parallax offset and the layer offset. If everything is
+// visible ok is false.
+func (v View) visible(info *LayerInfo) (r image.Rectangle, ok bool) {
+	if v.Bounds.Empty() {
+		return r, false
+	}
+	dx, dy := info.ParallaxOffset(v.CameraX, v.CameraY)
+	r = v.Bounds.Sub(image.Pt(int(math.Round(dx+info.offX)), int(math.Round(dy+info.offY))))
+	// one pixel of padding covers the rounding
+	return r.Inset(-1), true
+}
+
+// geoM returns the transformation of a layer element positioned by geom
+// in map pixels, moved by the parallax offset of the layer.
+func (v View) geoM(info *LayerInfo, geom ebiten.GeoM) ebiten.GeoM {
+	dx, dy := info.ParallaxOffset(v.CameraX, v.CameraY)
+	geom.Translate(dx, dy)
+	geom.Concat(v.GeoM)
+	return geom
+}
 
 // NewDrawer creates a Drawer which will render the layer and recursively draw all child layers.
 func NewDrawer(resources *Resources, parent Drawer, layer *tmx.Layer) (Drawer, error) {
@@ -45,25 +102,21 @@ func NewDrawer(resources *Resources, parent Drawer, layer *tmx.Layer) (Drawer, e
 // NewRootDrawer will create a special Drawer that will recursively draw the entire tmx map.
 func NewRootDrawer(resources *Resources, mapData *tmx.Map) (Drawer, error) {
 	info := &LayerInfo{
-		mapData: mapData,
-		layer:   nil,
-		w:       int(mapData.Width),
-		h:       int(mapData.Height),
-		offX:    0.0,
-		offY:    0.0,
-		color:   ebiten.ColorM{},
+		mapData:   mapData,
+		layer:     nil,
+		w:         int(mapData.Width),
+		h:         int(mapData.Height),
+		offX:      0.0,
+		offY:      0.0,
+		parallaxX: 1.0,
+		parallaxY: 1.0,
+		color:     ebiten.ColorM{},
 	}
 
-
 	gd := &groupDrawer{
 		info:     info,
 		children: make([]Drawer, 0),
 	}
-	gd.image, _ = ebiten.NewImage(
-		int(mapData.Width * mapData.TileWidth),
-		int(mapData.Height * mapData.TileHeight),
-		ebiten.FilterNearest,
-	)
 
 	for _, l := range mapData.Layers {
 		d, err := NewDrawer(resources, gd, l)
@@ -35,21 +35,41 @@ func (e *Example) Update(screen *ebiten.Image) error {
 		ebiten.SetFullscreen(!ebiten.IsFullscreen())
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyRight) {
-		e.cam.Pan(3*math.Pi/2, 30.0)
+		e.cam.Pan(3*math.Pi/2, 200.0)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
-		e.cam.Pan(math.Pi/2, 30.0)
+		e.cam.Pan(math.Pi/2, 200.0)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyUp) {
-		e.cam.Pan(0, 30.0)
+		e.cam.Pan(0, 200.0)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyDown) {
-		e.cam.Pan(math.Pi, 30)
+		e.cam.Pan(math.Pi, 200.0)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) {
+		x, y := ebiten.CursorPosition()
+		e.cam.ZoomAt(2.0, float64(x), float64(y))
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) {
+		x, y := ebiten.CursorPosition()
+		e.cam.ZoomAt(0.5, float64(x), float64(y))
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyQ) {
+		e.cam.Rotation -= e.cam.DT.Seconds()
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyE) {
+		e.cam.Rotation += e.cam.DT.Seconds()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		e.cam.Shake(4.0, 500*time.Millisecond)
 	}
 	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
 		return errors.New("quitting")
 	}
-	e.cam.Draw(e.mapDrawer.Image(), screen)
+	err := e.cam.DrawMap(e.mapDrawer, screen)
+	if err != nil {
+		return err
+	}
 	end := time.Now()
 	msg := fmt.Sprintf("FPS: %0.2f, Pos: %v, DT: %0.3f ms, Draw: %0.3f ms", ebiten.CurrentFPS(), e.cam, e.cam.DT.Seconds() * 1000, end.Sub(start).Seconds() * 1000)
 	ebitenutil.DebugPrint(screen, msg)
@@ -66,6 +86,8 @@ func main() {
 	tld, err := ebitentmx.NewRootDrawer(resources, mapData)
 	noError(err)
 	c := ebitentmx.NewCamera()
+	c.PixelPerfect = true
+	c.ClampToMap(mapData)
 	e := &Example{cam: c, mapDrawer: tld}
 	err = ebiten.Run(
 		e.Update,
@@ -7,20 +7,26 @@ import (
 	"github.com/elliotmr/tmx"
 )
 
-func calcGeoM(tile tmx.TileInstance, rect image.Rectangle) ebiten.GeoM {
+// calcGeoM returns the transformation that draws the src tile into rect,
+// scaling it when the rect does not have the size of the (possibly
+// diagonally flipped) tile.
+func calcGeoM(tile tmx.TileInstance, src, rect image.Rectangle) ebiten.GeoM {
 	geom := ebiten.GeoM{}
+	w, h := float64(src.Dx()), float64(src.Dy())
 	if tile.FlippedDiagonally() {
-		geom.Rotate(math.Pi)
-		geom.Translate(float64(rect.Dx()), float64(rect.Dy()))
+		geom.Rotate(math.Pi / 2)
+		geom.Scale(-1.0, 1.0)
+		w, h = h, w
 	}
 	if tile.FlippedHorizontally() {
 		geom.Scale(-1.0, 1.0)
-		geom.Translate(float64(rect.Dx()), 0)
+		geom.Translate(w, 0)
 	}
 	if tile.FlippedVertically() {
 		geom.Scale(1.0, -1.0)
-		geom.Translate(0, float64(rect.Dy()))
+		geom.Translate(0, h)
 	}
+	geom.Scale(float64(rect.Dx())/w, float64(rect.Dy())/h)
 	geom.Translate(
 		float64(rect.Min.X),
 		float64(rect.Min.Y),
@@ -28,20 +34,26 @@ func calcGeoM(tile tmx.TileInstance, rect image.Rectangle) ebiten.GeoM {
 	return geom
 }
 
-func calcObjectGeoM(obj *tmx.Object, srcRect image.Rectangle) ebiten.GeoM {
+// calcObjectGeoM returns the transformation for a tile object. The anchor of
+// the tile given by the tileset object alignment is placed at the object
+// position, which is also the pivot of the object rotation. The tileset offset
+// rotates together with the object.
+func calcObjectGeoM(obj *tmx.Object, entry tileSetEntry, orientation string) ebiten.GeoM {
+	srcRect := *entry.rect
 	geom := ebiten.GeoM{}
 	tile := tmx.TileInstance(*obj.GID)
+	w, h := entry.size(tile)
 	if tile.FlippedDiagonally() {
-		geom.Rotate(math.Pi)
-		geom.Translate(float64(srcRect.Dx()), float64(srcRect.Dy()))
+		geom.Rotate(math.Pi / 2)
+		geom.Scale(-1.0, 1.0)
 	}
 	if tile.FlippedHorizontally() {
 		geom.Scale(-1.0, 1.0)
-		geom.Translate(float64(srcRect.Dx()), 0)
+		geom.Translate(float64(w), 0)
 	}
 	if tile.FlippedVertically() {
 		geom.Scale(1.0, -1.0)
-		geom.Translate(0, float64(srcRect.Dy()))
+		geom.Translate(0, float64(h))
 	}
 	scaleX := 1.0
 	if obj.Width != nil {
@@ -52,9 +64,15 @@ func calcObjectGeoM(obj *tmx.Object, srcRect image.Rectangle) ebiten.GeoM {
 		scaleY = *obj.Height / float64(srcRect.Dy())
 	}
 	geom.Scale(scaleX, scaleY)
+	offX, offY := entry.offset()
+	ax, ay := entry.tileSet.ObjectAnchor(orientation)
+	geom.Translate(
+		float64(offX)-ax*float64(w)*scaleX,
+		float64(offY)-ay*float64(h)*scaleY,
+	)
 	if obj.Rotation != nil {
 		geom.Rotate(*obj.Rotation * math.Pi / 180.0)
 	}
-	geom.Translate(obj.X, obj.Y - float64(srcRect.Dy()) * scaleY)
+	geom.Translate(obj.X, obj.Y)
 	return geom
 }
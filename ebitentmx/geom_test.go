@@ -0,0 +1,35 @@
+package ebitentmx
+
+import (
+	"image"
+	"testing"
+
+	"github.com/elliotmr/tmx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalcGeoMFlips(t *testing.T) {
+	// a 16x8 tile is drawn into an 8x16 rect once it is flipped diagonally
+	src := image.Rect(32, 0, 48, 8)
+	rect := image.Rect(100, 200, 108, 216)
+	for _, test := range []struct {
+		name  string
+		flags uint32
+		x, y  func(x, y float64) float64 // Where the source pixel (x, y) is drawn within rect.
+	}{
+		{"diagonal", tmx.FlippedDiagonallyFlag,
+			func(x, y float64) float64 { return y }, func(x, y float64) float64 { return x }},
+		// diagonal and horizontal flips are a clockwise rotation in Tiled
+		{"clockwise", tmx.FlippedDiagonallyFlag | tmx.FlippedHorizontallyFlag,
+			func(x, y float64) float64 { return 8 - y }, func(x, y float64) float64 { return x }},
+		{"counter-clockwise", tmx.FlippedDiagonallyFlag | tmx.FlippedVerticallyFlag,
+			func(x, y float64) float64 { return y }, func(x, y float64) float64 { return 16 - x }},
+	} {
+		geom := calcGeoM(tmx.TileInstance(test.flags|1), src, rect)
+		for _, p := range [][2]float64{{0, 0}, {16, 0}, {0, 8}, {16, 8}, {4, 2}} {
+			x, y := geom.Apply(p[0], p[1])
+			assert.InDelta(t, 100+test.x(p[0], p[1]), x, 1e-9, "%s %v", test.name, p)
+			assert.InDelta(t, 200+test.y(p[0], p[1]), y, 1e-9, "%s %v", test.name, p)
+		}
+	}
+}
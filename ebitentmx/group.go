@@ -2,16 +2,11 @@ package ebitentmx
 
 import (
 	"github.com/hajimehoshi/ebiten"
-	"github.com/pkg/errors"
-	"image"
 )
 
 type groupDrawer struct {
-	resources *Resources
 	info     *LayerInfo
 	children []Drawer
-	opts      *ebiten.DrawImageOptions
-	image     *ebiten.Image
 }
 
 func newGroupDrawer(resources *Resources, info *LayerInfo) (*groupDrawer, error) {
@@ -27,39 +22,6 @@ func newGroupDrawer(resources *Resources, info *LayerInfo) (*groupDrawer, error)
 		}
 		gd.children = append(gd.children, d)
 	}
-
-	var min, max image.Point
-	if len(gd.children) > 0 {
-		min = gd.children[0].Image().Bounds().Min
-		max = gd.children[0].Image().Bounds().Max
-	}
-
-	for _, child := range gd.children {
-		bounds := child.Image().Bounds()
-		if bounds.Min.X < min.X {
-			min.X = bounds.Min.X
-		}
-		if bounds.Min.Y < min.Y {
-			min.Y = bounds.Min.Y
-		}
-		if bounds.Max.X > max.X {
-			max.X = bounds.Max.X
-		}
-		if bounds.Max.Y > max.Y {
-			max.Y = bounds.Max.Y
-		}
-	}
-
-	bounds := image.Rectangle{Min:min, Max:max}
-	gd.image, _ = ebiten.NewImage(bounds.Dx(), bounds.Dy(), ebiten.FilterNearest)
-	geom := ebiten.GeoM{}
-	geom.Translate(info.offX, info.offY)
-	gd.opts = &ebiten.DrawImageOptions{
-		SourceRect: &bounds,
-		GeoM:       geom,
-		Filter:     ebiten.FilterNearest,
-	}
-
 	return gd, nil
 }
 
@@ -71,16 +33,6 @@ func (gd *groupDrawer) Info() *LayerInfo {
 	return gd.info
 }
 
-func (gd *groupDrawer) Image() *ebiten.Image {
-	for _, child := range gd.children {
-		err := child.Draw(gd.image)
-		if err != nil {
-			return nil
-		}
-	}
-	return gd.image
-}
-
 func (gd *groupDrawer) Update() error {
 	for _, child := range gd.children {
 		err := child.Update()
@@ -91,13 +43,15 @@ func (gd *groupDrawer) Update() error {
 	return nil
 }
 
-func (gd *groupDrawer) Draw(image *ebiten.Image) error {
+// Draw draws the children directly to the destination image, the offsets,
+// parallax factors and colors of the group are already part of their info.
+func (gd *groupDrawer) Draw(image *ebiten.Image, view View) error {
 	for _, child := range gd.children {
-		err := child.Draw(gd.image)
+		err := child.Draw(image, view)
 		if err != nil {
 			return err
 		}
 	}
-	return errors.Wrap(image.DrawImage(gd.image, gd.opts), "unable to draw layer")
+	return nil
 }
 
@@ -1,7 +1,8 @@
 package ebitentmx
 
 import (
-	"path/filepath"
+	"image"
+	"math"
 
 	"github.com/hajimehoshi/ebiten"
 	"github.com/pkg/errors"
@@ -11,7 +12,8 @@ type imageLayerDrawer struct {
 	resources *Resources
 	source    string
 	info      *LayerInfo
-	opts      *ebiten.DrawImageOptions
+	opts      []*ebiten.DrawImageOptions
+	rects     []image.Rectangle // the area covered by each repeated image in map pixels
 	image     *ebiten.Image
 }
 
@@ -25,13 +27,7 @@ func newImageLayerDriver(resources *Resources, info *LayerInfo) (*imageLayerDraw
 		info:      info,
 	}
 
-	if filepath.IsAbs(info.layer.Image.Source) {
-		ild.source = filepath.Clean(info.layer.Image.Source)
-	} else {
-		ild.source = filepath.Join(resources.path, info.layer.Image.Source)
-	}
-
-
+	ild.source = info.layer.Image.Key(resources.path)
 
 	return ild, ild.Update()
 }
@@ -44,10 +40,6 @@ func (ild *imageLayerDrawer) Info() *LayerInfo {
 	return ild.info
 }
 
-func (ild *imageLayerDrawer) Image() *ebiten.Image {
-	return ild.image
-}
-
 func (ild *imageLayerDrawer) Update() error {
 	var exists bool
 	ild.image, exists = ild.resources.images[ild.source]
@@ -55,17 +47,61 @@ func (ild *imageLayerDrawer) Update() error {
 		return errors.Errorf("image source '%s' not found", ild.source)
 	}
 
+	// repeated images are tiled over the whole map area
 	bounds := ild.image.Bounds()
-	geom := ebiten.GeoM{}
-	geom.Translate(ild.info.offX, ild.info.offY)
-	ild.opts = &ebiten.DrawImageOptions{
-		SourceRect: &bounds,
-		GeoM:       geom,
-		Filter:     ebiten.FilterNearest,
+	w := float64(bounds.Dx())
+	h := float64(bounds.Dy())
+	xs := repeatPositions(ild.info.offX, w, ild.info.layer.RepeatX, float64(ild.info.mapData.Width*ild.info.mapData.TileWidth))
+	ys := repeatPositions(ild.info.offY, h, ild.info.layer.RepeatY, float64(ild.info.mapData.Height*ild.info.mapData.TileHeight))
+	ild.opts = ild.opts[:0]
+	ild.rects = ild.rects[:0]
+	for _, y := range ys {
+		for _, x := range xs {
+			// the rects are culled in layer coordinates, without the offset
+			at := image.Pt(int(math.Floor(x-ild.info.offX)), int(math.Floor(y-ild.info.offY)))
+			ild.rects = append(ild.rects, bounds.Sub(bounds.Min).Add(at).Inset(-1))
+			geom := ebiten.GeoM{}
+			geom.Translate(x, y)
+			ild.opts = append(ild.opts, &ebiten.DrawImageOptions{
+				SourceRect: &bounds,
+				GeoM:       geom,
+				ColorM:     ild.info.color,
+				Filter:     ebiten.FilterNearest,
+			})
+		}
 	}
 	return nil
 }
 
-func (ild *imageLayerDrawer) Draw(image *ebiten.Image) error {
-	return errors.Wrap(image.DrawImage(ild.image, ild.opts), "unable to draw image layer")
+// repeatPositions returns the positions along one axis at which an image of
+// the given size is drawn. Without repeat this is only the layer offset.
+func repeatPositions(offset, size float64, repeat *int, length float64) []float64 {
+	if repeat == nil || *repeat == 0 || size <= 0 {
+		return []float64{offset}
+	}
+	start := math.Mod(offset, size)
+	if start > 0 {
+		start -= size
+	}
+	var positions []float64
+	for p := start; p < length; p += size {
+		positions = append(positions, p)
+	}
+	return positions
+}
+
+func (ild *imageLayerDrawer) Draw(dst *ebiten.Image, view View) error {
+	visible, cull := view.visible(ild.info)
+	for i, o := range ild.opts {
+		if cull && !ild.rects[i].Overlaps(visible) {
+			continue
+		}
+		opts := *o
+		opts.GeoM = view.geoM(ild.info, o.GeoM)
+		err := dst.DrawImage(ild.image, &opts)
+		if err != nil {
+			return errors.Wrap(err, "unable to draw image layer")
+		}
+	}
+	return nil
 }
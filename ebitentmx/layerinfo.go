@@ -1,27 +1,30 @@
 package ebitentmx
 
 import (
-	"strconv"
-	"strings"
+	"image"
+	"math"
 
 	"github.com/elliotmr/tmx"
 	"github.com/pkg/errors"
 	"github.com/hajimehoshi/ebiten"
-	"image"
 )
 
 // LayerInfo provides drawing information for the layer, it holds the
-// recursively calculated offset, visibility, and color information, as
-// well as a reference to the base map data. It prevides easy methods
+// recursively calculated offset, parallax, visibility, and color information,
+// as well as a reference to the base map data. It prevides easy methods
 // translating between tmx and pixel world coordinates.
 type LayerInfo struct {
-	mapData *tmx.Map
-	layer   *tmx.Layer
-	w       int
-	h       int
-	offX    float64
-	offY    float64
-	color   ebiten.ColorM
+	mapData   *tmx.Map
+	layer     *tmx.Layer
+	x0        int
+	y0        int
+	w         int
+	h         int
+	offX      float64
+	offY      float64
+	parallaxX float64
+	parallaxY float64
+	color     ebiten.ColorM
 }
 
 func newLayerInfo(parent *LayerInfo, layer *tmx.Layer) (*LayerInfo, error) {
@@ -32,20 +35,82 @@ func newLayerInfo(parent *LayerInfo, layer *tmx.Layer) (*LayerInfo, error) {
 	offX, offY := extractLayerOffsets(layer)
 	li.offX += offX
 	li.offY += offY
-	c, err := extractLayerColor(layer)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to extract color")
-	}
-	li.color.Concat(c)
+	// tints and opacities multiply down the layer tree
+	li.color.Concat(extractLayerColor(layer))
+	px, py := layer.Parallax()
+	li.parallaxX *= px
+	li.parallaxY *= py
 	if layer.Width != nil {
 		li.w = int(*layer.Width)
 	}
 	if layer.Height != nil {
 		li.h = int(*layer.Height)
 	}
+	if layer.Data != nil {
+		// tile layers of infinite maps cover the area of their chunks.
+		if x, y, w, h, ok := layer.Data.Bounds(); ok {
+			li.x0, li.y0, li.w, li.h = x, y, w, h
+		}
+	}
 	return li, nil
 }
 
+// ID returns the unique ID of the layer, or 0 for the root of the map.
+func (li *LayerInfo) ID() uint32 {
+	if li.layer == nil {
+		return 0
+	}
+	return li.layer.ID
+}
+
+// Class returns the class of the layer.
+func (li *LayerInfo) Class() string {
+	if li.layer == nil || li.layer.Class == nil {
+		return ""
+	}
+	return *li.layer.Class
+}
+
+// Parallax returns the effective parallax factors of the layer, which are
+// the factors of the layer multiplied with those of all its parent groups.
+func (li *LayerInfo) Parallax() (float64, float64) {
+	return li.parallaxX, li.parallaxY
+}
+
+// ParallaxOffset returns the distance in map pixels the layer is moved by
+// when the camera is centered on (camX, camY).
+func (li *LayerInfo) ParallaxOffset(camX, camY float64) (float64, float64) {
+	return li.mapData.ParallaxOffset(li.parallaxX, li.parallaxY, camX, camY)
+}
+
+// Bounds returns the area covered by the layer in tiles. The origin is only
+// different from (0, 0) for tile layers of infinite maps.
+func (li *LayerInfo) Bounds() (x, y, w, h int) {
+	return li.x0, li.y0, li.w, li.h
+}
+
+// cellRange returns the cells of the layer, relative to the origin of the
+// layer bounds, whose tiles intersect the rectangle r in layer pixels. The
+// margin tells how far tile images reach outside of their cell.
+func (li *LayerInfo) cellRange(r, margin image.Rectangle) image.Rectangle {
+	tw := float64(li.mapData.TileWidth)
+	th := float64(li.mapData.TileHeight)
+	x0 := float64(li.x0) * tw
+	y0 := float64(li.y0) * th
+	cells := image.Rect(
+		int(math.Floor((float64(r.Min.X-margin.Max.X)-x0)/tw)),
+		int(math.Floor((float64(r.Min.Y-margin.Max.Y)-y0)/th)),
+		int(math.Ceil((float64(r.Max.X-margin.Min.X)-x0)/tw)),
+		int(math.Ceil((float64(r.Max.Y-margin.Min.Y)-y0)/th)),
+	)
+	return cells.Intersect(image.Rect(0, 0, li.w, li.h))
+}
+
+// cell returns the cell index of a tile position within the layer bounds.
+func (li *LayerInfo) cell(x, y int) int {
+	return (y-li.y0)*li.w + (x - li.x0)
+}
+
 func extractLayerOffsets(layer *tmx.Layer) (float64, float64) {
 	if layer == nil {
 		return 0.0, 0.0
@@ -61,60 +126,28 @@ func extractLayerOffsets(layer *tmx.Layer) (float64, float64) {
 	return offX, offY
 }
 
-func extractLayerColor(layer *tmx.Layer) (ebiten.ColorM, error) {
-	rgba := ebiten.ColorM{}
-	if layer == nil {
-		return rgba, errors.New("nil layer passed")
-	}
+// extractLayerColor returns the color matrix of the layer, which scales by
+// its tint color and opacity.
+func extractLayerColor(layer *tmx.Layer) ebiten.ColorM {
 	opacity := 1.0
 	if layer.Opacity != nil {
 		opacity = *layer.Opacity
 	}
-	if layer.Visible != nil {
-		if *layer.Visible == 0 {
-			opacity = 0
-		}
+	if layer.Visible != nil && *layer.Visible == 0 {
+		opacity = 0
 	}
-
-	rgba.Scale(1.0, 1.0, 1.0, opacity)
-
-	if layer.Color != nil {
-		s := strings.Trim(*layer.Color, "# ")
-		var err error
-		i := 0
-		var a, r, b, g uint64
-		switch len(s) {
-		case 8:
-			a, err = strconv.ParseUint(s[:2], 16, 8)
-			if err != nil {
-				return rgba, err
-			}
-			i = 2
-			fallthrough
-		case 6:
-			r, err = strconv.ParseUint(s[i:i+2], 16, 8)
-			if err != nil {
-				return rgba, err
-			}
-			b, err = strconv.ParseUint(s[i+2:i+4], 16, 8)
-			if err != nil {
-				return rgba, err
-			}
-			g, err = strconv.ParseUint(s[i+4:i+6], 16, 8)
-			if err != nil {
-				return rgba, err
-			}
-		default:
-			return rgba, errors.Errorf("invalid color: %s", s)
-		}
-		rgba.Scale(
-			float64(r) / 255.0,
-			float64(g) / 255.0,
-			float64(b) / 255.0,
-			float64(a) / 255.0,
-		)
+	c := tmx.Color{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	if layer.TintColor != nil {
+		c = *layer.TintColor
 	}
-	return rgba, nil
+	cm := ebiten.ColorM{}
+	cm.Scale(
+		float64(c.R)/255.0,
+		float64(c.G)/255.0,
+		float64(c.B)/255.0,
+		float64(c.A)/255.0*opacity,
+	)
+	return cm
 }
 
 // TileRect returns the pixel.Rect of a TMX map tile in pixel world coordinates.
@@ -131,4 +164,19 @@ func (li *LayerInfo) TileRect(cell int) (image.Rectangle, error) {
 		int(cell/li.w)*th + th,
 	)
 	return rect, nil
-}
\ No newline at end of file
+}
+
+// TileImageRect returns the image.Rectangle of a w x h tile image placed in a
+// TMX map tile, relative to the origin of the layer bounds. As in Tiled, the
+// image is anchored to the bottom-left corner of the map tile and moved by the
+// tileset offset (offX, offY).
+func (li *LayerInfo) TileImageRect(cell, w, h, offX, offY int) (image.Rectangle, error) {
+	if cell > (li.w * li.h) {
+		return image.Rect(0, 0, 0, 0), errors.Errorf("cell out of range (%d > %d)", cell, li.w*li.h)
+	}
+	tw := int(li.mapData.TileWidth)
+	th := int(li.mapData.TileHeight)
+	x := (cell%li.w)*tw + offX
+	y := (cell/li.w+1)*th - h + offY
+	return image.Rect(x, y, x+w, y+h), nil
+}
@@ -2,39 +2,72 @@ package ebitentmx
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
 
 	"github.com/elliotmr/tmx"
+	"github.com/elliotmr/tmx/tmxtext"
 	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/text"
 	"github.com/pkg/errors"
-	"sort"
 )
 
+// objectOp is a single object prepared for drawing, either an image of a
+// tile or text, or a shape made of triangles.
+type objectOp struct {
+	image  *ebiten.Image
+	opts   ebiten.DrawImageOptions
+	shape  *shape
+	bounds image.Rectangle // the area covered by the object in layer pixels
+}
+
+// calcBounds computes the bounding box of the drawn object.
+func (op *objectOp) calcBounds() {
+	var xs, ys []float64
+	switch {
+	case op.image != nil:
+		src := op.image.Bounds()
+		if op.opts.SourceRect != nil {
+			src = *op.opts.SourceRect
+		}
+		w, h := float64(src.Dx()), float64(src.Dy())
+		for _, p := range []vec{{0, 0}, {w, 0}, {w, h}, {0, h}} {
+			x, y := op.opts.GeoM.Apply(p.x, p.y)
+			xs, ys = append(xs, x), append(ys, y)
+		}
+	case op.shape != nil:
+		for _, v := range op.shape.vertices {
+			xs, ys = append(xs, float64(v.DstX)), append(ys, float64(v.DstY))
+		}
+	}
+	if len(xs) == 0 {
+		return
+	}
+	minX, minY, maxX, maxY := xs[0], ys[0], xs[0], ys[0]
+	for i := range xs {
+		minX, maxX = math.Min(minX, xs[i]), math.Max(maxX, xs[i])
+		minY, maxY = math.Min(minY, ys[i]), math.Max(maxY, ys[i])
+	}
+	op.bounds = image.Rect(
+		int(math.Floor(minX)), int(math.Floor(minY)),
+		int(math.Ceil(maxX)), int(math.Ceil(maxY)),
+	).Inset(-1)
+}
+
 type objectGroupDrawer struct {
-	resources *Resources
-	info      *LayerInfo
-	opts      *ebiten.DrawImageOptions
-	image     *ebiten.Image
+	resources  *Resources
+	info       *LayerInfo
+	ops        []objectOp
+	vertices   []ebiten.Vertex
+	textImages []*ebiten.Image
 }
 
 func newObjectGroupDrawer(resources *Resources, info *LayerInfo) (*objectGroupDrawer, error) {
-	img, _ := ebiten.NewImage(
-		info.w*int(info.mapData.TileWidth), // TODO: this is incorrect
-		info.h*int(info.mapData.TileHeight), // TODO: this is incorrect
-		ebiten.FilterNearest,
-	)
-	bounds := img.Bounds()
-	geom := ebiten.GeoM{}
-	geom.Translate(info.offX, info.offY)
-	opts := &ebiten.DrawImageOptions{
-		SourceRect: &bounds,
-		GeoM:       geom,
-		Filter:     ebiten.FilterNearest,
-	}
 	od := &objectGroupDrawer{
 		resources: resources,
 		info:      info,
-		opts:      opts,
-		image:     img,
 	}
 	return od, od.Update()
 }
@@ -47,8 +80,92 @@ func (ogd *objectGroupDrawer) Info() *LayerInfo {
 	return ogd.info
 }
 
-func (ogd *objectGroupDrawer) Image() *ebiten.Image {
-	return ogd.image
+// objectColor returns the color of the object group, shapes are drawn in it.
+func (ogd *objectGroupDrawer) objectColor() color.Color {
+	if ogd.info.layer.Color != nil {
+		return *ogd.info.layer.Color
+	}
+	return color.White
+}
+
+// objectGeoM returns the transformation from object to map pixels: the
+// object is rotated around its position.
+func objectGeoM(obj *tmx.Object) ebiten.GeoM {
+	geom := ebiten.GeoM{}
+	if obj.Rotation != nil {
+		geom.Rotate(*obj.Rotation * math.Pi / 180.0)
+	}
+	geom.Translate(obj.X, obj.Y)
+	return geom
+}
+
+// closedShape builds a filled and/or outlined shape according to the style.
+func (ogd *objectGroupDrawer) closedShape(obj *tmx.Object, pts []vec) *shape {
+	style := ogd.resources.ObjectStyle
+	s := &shape{}
+	geom := objectGeoM(obj)
+	if style.Fill {
+		newShapeBuilder(s, geom, ogd.objectColor(), style.FillAlpha).fill(pts)
+	}
+	if style.Outline {
+		newShapeBuilder(s, geom, ogd.objectColor(), 1.0).stroke(pts, true, style.LineWidth)
+	}
+	return s
+}
+
+// textOp renders the text into an image the size of the object, which is
+// rotated around the top-left corner of the object like in Tiled.
+func (ogd *objectGroupDrawer) textOp(obj *tmx.Object) (objectOp, error) {
+	face, err := ogd.resources.Fonts.Face(obj.Text.Font())
+	if err != nil {
+		return objectOp{}, err
+	}
+	var lines []tmxtext.Line
+	w, h := 0.0, 0.0
+	if obj.Width != nil && obj.Height != nil {
+		w, h = *obj.Width, *obj.Height
+		lines = tmxtext.Layout(obj.Text, face, w, h)
+	} else {
+		// without a size the image fits the text
+		lines = tmxtext.Layout(obj.Text, face, 0, 0)
+		for _, line := range lines {
+			w = math.Max(w, line.Width)
+		}
+		h = float64(face.Metrics().Height) / 64 * float64(len(lines))
+	}
+	img, err := ebiten.NewImage(int(math.Max(1, math.Ceil(w))), int(math.Max(1, math.Ceil(h))), ebiten.FilterNearest)
+	if err != nil {
+		return objectOp{}, errors.Wrap(err, "unable to create text image")
+	}
+	ogd.textImages = append(ogd.textImages, img)
+
+	c := obj.Text.TextColor()
+	s := &shape{}
+	sb := newShapeBuilder(s, ebiten.GeoM{}, c, 1.0)
+	for _, line := range lines {
+		text.Draw(img, line.Text, face, int(math.Round(line.X)), int(math.Round(line.Y)), c)
+		for _, d := range tmxtext.Decorations(obj.Text, face, line) {
+			pts := rectPoints(d.W, d.H)
+			for i := range pts {
+				pts[i].x += d.X
+				pts[i].y += d.Y
+			}
+			sb.fill(pts)
+		}
+	}
+	if len(s.indices) > 0 {
+		img.DrawTriangles(s.vertices, s.indices, ogd.resources.white, &ebiten.DrawTrianglesOptions{
+			Filter: ebiten.FilterNearest,
+		})
+	}
+	return objectOp{
+		image: img,
+		opts: ebiten.DrawImageOptions{
+			ColorM: ogd.info.color,
+			GeoM:   objectGeoM(obj),
+			Filter: ebiten.FilterNearest,
+		},
+	}, nil
 }
 
 func (ogd *objectGroupDrawer) Update() error {
@@ -60,20 +177,31 @@ func (ogd *objectGroupDrawer) Update() error {
 	}
 
 	if ogd.info.layer.DrawOrder == nil || *ogd.info.layer.DrawOrder != "index" {
-		sort.Slice(objIndex, func(i, j int) bool {
+		sort.SliceStable(objIndex, func(i, j int) bool {
 			return objs[objIndex[i]].Y < objs[objIndex[j]].Y
 		})
 	}
 
+	ogd.ops = ogd.ops[:0]
+	for _, img := range ogd.textImages {
+		_ = img.Dispose()
+	}
+	ogd.textImages = ogd.textImages[:0]
 	for _, i := range objIndex {
 		obj := objs[i]
 		if obj.Visible != nil && *obj.Visible == 0 {
 			continue // skip invisible objects
 		}
+		w, h := 0.0, 0.0
+		if obj.Width != nil {
+			w = *obj.Width
+		}
+		if obj.Height != nil {
+			h = *obj.Height
+		}
 		switch {
 		case obj.GID != nil:
 			tile := tmx.TileInstance(*obj.GID)
-			tse := ogd.resources.entries[tile.GID()]
 			entry, exists := ogd.resources.entries[tile.GID()]
 			if !exists {
 				fmt.Println("invalid object: ", tile.GID())
@@ -84,39 +212,95 @@ func (ogd *objectGroupDrawer) Update() error {
 				fmt.Println("invalid object: ", tile.GID())
 				continue
 			}
-
-			opts := &ebiten.DrawImageOptions{
-				SourceRect: tse.rect,
-				ColorM:     ogd.info.color,
-				GeoM:       calcObjectGeoM(obj, *tse.rect),
-				Filter:     ebiten.FilterNearest,
-			}
-
-			ogd.image.DrawImage(pic, opts)
+			ogd.ops = append(ogd.ops, objectOp{
+				image: pic,
+				opts: ebiten.DrawImageOptions{
+					SourceRect: entry.rect,
+					ColorM:     ogd.info.color,
+					GeoM:       calcObjectGeoM(obj, entry, ogd.info.mapData.Orientation),
+					Filter:     ebiten.FilterNearest,
+				},
+			})
 		case obj.Ellipse != nil:
-			fmt.Println("ERROR ELLIPSE OBJECT NOT IMPLEMENTED!")
-			continue
+			if obj.Width == nil || obj.Height == nil {
+				return errors.New("ellipse without width or height set")
+			}
+			pts := ellipsePoints(w/2, h/2, w/2, h/2)
+			ogd.ops = append(ogd.ops, objectOp{shape: ogd.closedShape(obj, pts)})
 		case obj.Point != nil:
-			fmt.Println("ERROR POINT OBJECT NOT IMPLEMENTED!")
-			continue
+			r := ogd.resources.ObjectStyle.PointRadius
+			pts := ellipsePoints(0, 0, r, r)
+			ogd.ops = append(ogd.ops, objectOp{shape: ogd.closedShape(obj, pts)})
 		case obj.Polygon != nil:
-			fmt.Println("ERROR POLYGON OBJECT NOT IMPLEMENTED!")
-			continue
+			pts, err := parsePoints(obj.Polygon.Points)
+			if err != nil {
+				return errors.Wrap(err, "invalid polygon")
+			}
+			ogd.ops = append(ogd.ops, objectOp{shape: ogd.closedShape(obj, pts)})
 		case obj.Polyline != nil:
-			fmt.Println("ERROR POLYLINE OBJECT NOT IMPLEMENTED!")
-			continue
+			pts, err := parsePoints(obj.Polyline.Points)
+			if err != nil {
+				return errors.Wrap(err, "invalid polyline")
+			}
+			s := &shape{}
+			newShapeBuilder(s, objectGeoM(obj), ogd.objectColor(), 1.0).stroke(pts, false, ogd.resources.ObjectStyle.LineWidth)
+			ogd.ops = append(ogd.ops, objectOp{shape: s})
 		case obj.Text != nil:
-			fmt.Println("ERROR TEXT OBJECT NOT IMPLEMENTED!")
-			continue
+			op, err := ogd.textOp(obj)
+			if err != nil {
+				return errors.Wrap(err, "invalid text")
+			}
+			ogd.ops = append(ogd.ops, op)
 		default: // Box
-			fmt.Println("ERROR BOX OBJECT NOT IMPLEMENTED!")
-			continue
+			if obj.Width == nil || obj.Height == nil {
+				return errors.New("rectangle without width or height set")
+			}
+			ogd.ops = append(ogd.ops, objectOp{shape: ogd.closedShape(obj, rectPoints(w, h))})
 		}
 	}
+	for i := range ogd.ops {
+		ogd.ops[i].calcBounds()
+	}
 	return nil
 }
 
-func (ogd *objectGroupDrawer) Draw(image *ebiten.Image) error {
-	return errors.Wrap(image.DrawImage(ogd.image, ogd.opts), "unable to draw object layer")
+// Draw draws the objects intersecting the visible bounds of the view
+// directly to the destination image.
+func (ogd *objectGroupDrawer) Draw(dst *ebiten.Image, view View) error {
+	offset := ebiten.GeoM{}
+	offset.Translate(ogd.info.offX, ogd.info.offY)
+	geom := view.geoM(ogd.info, offset)
+	visible, cull := view.visible(ogd.info)
+	for i := range ogd.ops {
+		op := &ogd.ops[i]
+		if cull && !op.bounds.Overlaps(visible) {
+			continue
+		}
+		switch {
+		case op.image != nil:
+			opts := op.opts
+			opts.GeoM.Concat(geom)
+			err := dst.DrawImage(op.image, &opts)
+			if err != nil {
+				return errors.Wrap(err, "unable to draw object layer")
+			}
+		case op.shape != nil:
+			ogd.drawShape(dst, op.shape, geom)
+		}
+	}
+	return nil
 }
 
+// drawShape draws the triangles of the shape transformed by geom.
+func (ogd *objectGroupDrawer) drawShape(dst *ebiten.Image, s *shape, geom ebiten.GeoM) {
+	ogd.vertices = append(ogd.vertices[:0], s.vertices...)
+	for i := range ogd.vertices {
+		v := &ogd.vertices[i]
+		x, y := geom.Apply(float64(v.DstX), float64(v.DstY))
+		v.DstX, v.DstY = float32(x), float32(y)
+	}
+	dst.DrawTriangles(ogd.vertices, s.indices, ogd.resources.white, &ebiten.DrawTrianglesOptions{
+		ColorM: ogd.info.color,
+		Filter: ebiten.FilterNearest,
+	})
+}
@@ -0,0 +1,115 @@
+package ebitentmx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/elliotmr/tmx"
+	"github.com/pkg/errors"
+)
+
+// Reloader reloads a map together with its Resources and Drawer whenever the
+// map file or one of its tilesets, templates or images changes. It is meant
+// for development, when maps are edited in Tiled while the game runs. Update
+// must be called between frames on the goroutine running the game, the new
+// version is only swapped in once it has been loaded completely and the
+// images of the old version are disposed.
+type Reloader struct {
+	Configure func(r *Resources) // Called on new resources before the drawer is created, e.g. to register fonts (optional).
+
+	path      string
+	watcher   *tmx.Watcher
+	mapData   *tmx.Map
+	resources *Resources
+	drawer    Drawer
+}
+
+// NewReloader loads the map at path and starts watching its files. The
+// resources are configured with configure, which may be nil.
+func NewReloader(path string, configure func(r *Resources)) (*Reloader, error) {
+	rl := &Reloader{Configure: configure, path: path}
+	m, res, d, err := rl.load()
+	if err != nil {
+		return nil, err
+	}
+	rl.mapData, rl.resources, rl.drawer = m, res, d
+	rl.watcher = tmx.NewWatcher(path, m)
+	return rl, nil
+}
+
+// load loads the map with its resources and drawer. If that fails, the
+// returned map is as much of the new version as could be decoded, or nil,
+// so that its files can be watched.
+func (rl *Reloader) load() (*tmx.Map, *Resources, Drawer, error) {
+	data, err := ioutil.ReadFile(rl.path)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "unable to read map")
+	}
+	m, err := tmx.LoadReader(bytes.NewReader(data), rl.path)
+	if err != nil {
+		// a tileset failed to load, the map file still names it
+		partial := &tmx.Map{}
+		if xml.Unmarshal(data, partial) != nil {
+			return nil, nil, nil, err
+		}
+		return partial, nil, nil, err
+	}
+	res, err := LoadResources(m, filepath.Dir(rl.path))
+	if err != nil {
+		return m, nil, nil, err
+	}
+	if rl.Configure != nil {
+		rl.Configure(res)
+	}
+	d, err := NewRootDrawer(res, m)
+	if err != nil {
+		res.dispose()
+		return m, nil, nil, errors.Wrap(err, "unable to create drawer")
+	}
+	return m, res, d, nil
+}
+
+// Update polls the watched files and reloads the map if any of them changed.
+// It returns true if a new version has been swapped in. If reloading fails
+// the last good version is kept and the error is returned. The files of the
+// failed version are watched along with those of the last good one, so the
+// map is tried again after the next change to any of them, e.g. when a
+// missing image is added.
+func (rl *Reloader) Update() (bool, error) {
+	if !rl.watcher.Changed() {
+		return false, nil
+	}
+	m, res, d, err := rl.load()
+	if err != nil {
+		if m != nil {
+			rl.watcher.Add(m)
+		}
+		return false, errors.Wrap(err, "unable to reload map")
+	}
+	rl.watcher.Watch(m)
+	rl.resources.dispose()
+	rl.mapData, rl.resources, rl.drawer = m, res, d
+	return true, nil
+}
+
+// Watcher returns the watcher polling the files, e.g. to change its interval.
+func (rl *Reloader) Watcher() *tmx.Watcher {
+	return rl.watcher
+}
+
+// Map returns the current version of the map.
+func (rl *Reloader) Map() *tmx.Map {
+	return rl.mapData
+}
+
+// Resources returns the resources of the current version of the map.
+func (rl *Reloader) Resources() *Resources {
+	return rl.resources
+}
+
+// Drawer returns the root drawer of the current version of the map.
+func (rl *Reloader) Drawer() Drawer {
+	return rl.drawer
+}
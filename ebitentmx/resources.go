@@ -1,63 +1,117 @@
 package ebitentmx
 
 import (
+	"context"
 	"image"
+	"image/color"
 	_ "image/png" // This is required for the parsing png resource files
-	"path/filepath"
-	"os"
 
+	"github.com/elliotmr/tmx"
+	"github.com/elliotmr/tmx/tmxtext"
 	"github.com/hajimehoshi/ebiten"
 	"github.com/pkg/errors"
-	"github.com/elliotmr/tmx"
 )
 
 type tileSetEntry struct {
 	rect     *image.Rectangle
 	firstGID uint32
 	source   string
+	tileSet  *tmx.TileSet
+}
+
+// offset returns the tileset drawing offset in pixels.
+func (tse tileSetEntry) offset() (int, int) {
+	if tse.tileSet == nil || tse.tileSet.Offset == nil {
+		return 0, 0
+	}
+	return int(tse.tileSet.Offset.X), int(tse.tileSet.Offset.Y)
+}
+
+// size returns the size of the drawn tile, taking the diagonal flip into
+// account.
+func (tse tileSetEntry) size(tile tmx.TileInstance) (int, int) {
+	if tile.FlippedDiagonally() {
+		return tse.rect.Dy(), tse.rect.Dx()
+	}
+	return tse.rect.Dx(), tse.rect.Dy()
 }
 
 type Resources struct {
+	ObjectStyle ObjectStyle           // The style shape objects are drawn with, it is applied when a drawer is updated.
+	Fonts       *tmxtext.FontRegistry // The fonts text objects are drawn with, register fonts before creating the drawers.
+	ChunkSize   int                   // The size in tiles of the chunks tile layers are rendered in, it is applied when a drawer is updated.
+	ChunkBudget int                   // The memory in bytes the rendered chunks of all layers may use.
+
 	path    string
 	entries map[uint32]tileSetEntry
 	images  map[string]*ebiten.Image
+	white   *ebiten.Image // the source of the triangles of shapes
+	chunks  *chunkCache
 }
 
-func (r *Resources) loadImage(source string) (string, error) {
-	if filepath.IsAbs(source) {
-		source = filepath.Clean(source)
-	} else {
-		source = filepath.Join(r.path, source)
+// dispose releases the images of the resources, neither the resources nor
+// the drawers using them can be used afterwards.
+func (r *Resources) dispose() {
+	r.chunks.clear()
+	for _, img := range r.images {
+		_ = img.Dispose()
 	}
-	imageFile, err := os.Open(source)
-	if err != nil {
-		return "", errors.Wrap(err, "unable to open tileset Image")
+	if r.white != nil {
+		_ = r.white.Dispose()
 	}
-	defer imageFile.Close()
-	tilesetImg, _, err := image.Decode(imageFile)
-	if err != nil {
-		return "", errors.Wrap(err, "unable to decode tileset Image")
-	}
-	pic, _ := ebiten.NewImageFromImage(tilesetImg, ebiten.FilterNearest)
-	r.images[source] = pic
-	return source, nil
 }
 
-func (r *Resources) loadLayer(layer *tmx.Layer) error {
-	// Load Images
-	if layer.Image != nil {
-		_, err := r.loadImage(layer.Image.Source)
-		if err != nil {
-			return err
+// LoadOptions configures LoadResourcesContext.
+type LoadOptions struct {
+	Workers  int                   // The maximum number of images decoded in parallel. Defaults to runtime.NumCPU().
+	Progress func(done, total int) // Called on the calling goroutine after each image has been decoded (optional).
+}
+
+func (r *Resources) loadTileSet(set *tmx.TileSet) error {
+	if set.Image == nil {
+		return r.loadImageCollection(set)
+	}
+	source := set.Image.Key(r.path)
+	bounds := r.images[source].Bounds()
+	for id := uint32(0); id < set.TileCount; id++ {
+		row := id / set.Columns
+		col := id % set.Columns
+		minX := int(set.Margin + col*(set.TileWidth+set.Spacing))
+		minY := int(set.Margin + row*(set.TileHeight+set.Spacing))
+		maxX := int(set.Margin + col*(set.TileWidth+set.Spacing) + set.TileWidth)
+		maxY := int(set.Margin + row*(set.TileHeight+set.Spacing) + set.TileHeight)
+		if minX < bounds.Min.X || minY < bounds.Min.Y || maxX > bounds.Max.X || maxY > bounds.Max.Y {
+			return errors.Errorf("tile %d bounds outside of texture bounds (%d, %d, %d, %d)", id, minX, minY, maxX, maxY)
+		}
+		rect := image.Rect(minX, minY, maxX, maxY)
+		r.entries[id+set.FirstGID] = tileSetEntry{
+			rect:     &rect,
+			firstGID: set.FirstGID,
+			source:   source,
+			tileSet:  set,
 		}
 	}
-	// TODO: Load Templates
+	return nil
+}
 
-	// walk the children recursively.
-	for _, child := range layer.Layers {
-		err := r.loadLayer(child)
-		if err != nil {
-			return err
+// loadImageCollection creates the entries for a tileset where every tile
+// has its own image. The rect of each tile covers its entire image.
+func (r *Resources) loadImageCollection(set *tmx.TileSet) error {
+	for _, tile := range set.Tiles {
+		if tile.Image == nil {
+			continue
+		}
+		source := tile.Image.Key(r.path)
+		img, exists := r.images[source]
+		if !exists {
+			return errors.Errorf("image for tile %d not loaded", tile.ID)
+		}
+		rect := img.Bounds()
+		r.entries[tile.ID+set.FirstGID] = tileSetEntry{
+			rect:     &rect,
+			firstGID: set.FirstGID,
+			source:   source,
+			tileSet:  set,
 		}
 	}
 	return nil
@@ -67,45 +121,70 @@ func (r *Resources) loadLayer(layer *tmx.Layer) error {
 // the resources are located somewhere other than the current working directory, the
 // location should be supplied in the path string.
 func LoadResources(mapData *tmx.Map, path string) (*Resources, error) {
+	return LoadResourcesContext(context.Background(), mapData, path, nil)
+}
+
+// LoadResourcesContext works like LoadResources, but decodes the images in
+// parallel. Loading stops early when the context is cancelled, otherwise all
+// failures are collected and returned together as a tmx.ErrorList. The ebiten
+// images are created on the calling goroutine.
+func LoadResourcesContext(ctx context.Context, mapData *tmx.Map, path string, opts *LoadOptions) (*Resources, error) {
 	// TODO: figure out how to abstract the file system (maybe use Afero?)
 	if path == "" {
 		path = "."
 	}
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+	decoded, err := tmx.DecodeImages(ctx, path, mapData.Images(), &tmx.DecodeOptions{
+		Workers:  opts.Workers,
+		Progress: opts.Progress,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load resources")
+	}
+	return newResources(mapData, path, decoded)
+}
+
+// newResources creates the resources of a map from its decoded images, the
+// ebiten images are created on the calling goroutine.
+func newResources(mapData *tmx.Map, path string, decoded map[string]image.Image) (*Resources, error) {
 	r := &Resources{
-		path:    path,
-		entries: make(map[uint32]tileSetEntry),
-		images:  make(map[string]*ebiten.Image),
+		ObjectStyle: DefaultObjectStyle,
+		Fonts:       tmxtext.NewFontRegistry(),
+		ChunkSize:   DefaultChunkSize,
+		ChunkBudget: DefaultChunkBudget,
+		path:        path,
+		entries:     make(map[uint32]tileSetEntry),
+		images:      make(map[string]*ebiten.Image),
+		chunks:      newChunkCache(),
 	}
-	for _, set := range mapData.TileSets {
-		source, err := r.loadImage(set.Image.Source)
+	for key, img := range decoded {
+		pic, err := ebiten.NewImageFromImage(img, ebiten.FilterNearest)
 		if err != nil {
-			return nil, err
-		}
-		bounds := r.images[source].Bounds()
-		for id := uint32(0); id < set.TileCount; id++ {
-			row := id / set.Columns
-			col := id % set.Columns
-			minX := int(set.Margin + col*(set.TileWidth+set.Spacing))
-			minY := int(set.Margin + row*(set.TileHeight+set.Spacing))
-			maxX := int(set.Margin + col*(set.TileWidth+set.Spacing) + set.TileWidth)
-			maxY := int(set.Margin + row*(set.TileHeight+set.Spacing) + set.TileHeight)
-			if minX < bounds.Min.X || minY < bounds.Min.Y || maxX > bounds.Max.X || maxY > bounds.Max.Y {
-				return nil, errors.Errorf("tile %d bounds outside of texture bounds (%f, %f, %f, %f)", id, minX, minY, maxX, maxY)
-			}
-			rect := image.Rect(minX, minY, maxX, maxY)
-			r.entries[id+set.FirstGID] = tileSetEntry{
-				rect:     &rect,
-				firstGID: set.FirstGID,
-				source:   source,
-			}
+			return nil, errors.Wrapf(err, "unable to create image '%s'", key)
 		}
+		r.images[key] = pic
+	}
+	var err error
+	r.white, err = ebiten.NewImage(3, 3, ebiten.FilterNearest)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create shape image")
+	}
+	err = r.white.Fill(color.White)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create shape image")
 	}
 
-	for _, l := range mapData.Layers {
-		err := r.loadLayer(l)
+	var el tmx.ErrorList
+	for _, set := range mapData.TileSets {
+		err := r.loadTileSet(set)
 		if err != nil {
-			return nil, errors.Wrap(err, "unable to load resources")
+			el = append(el, errors.Wrapf(err, "invalid tileset '%s'", set.Name))
 		}
 	}
+	if err := el.Err(); err != nil {
+		return nil, errors.Wrap(err, "unable to load resources")
+	}
 	return r, nil
 }
@@ -0,0 +1,212 @@
+package ebitentmx
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/pkg/errors"
+)
+
+// ObjectStyle configures how the shapes of object groups are drawn. The
+// shapes use the color of their object group, or white if it has none.
+type ObjectStyle struct {
+	Fill        bool    // Whether closed shapes (rectangles, ellipses, points and polygons) are filled.
+	FillAlpha   float64 // The opacity of the fill relative to the object group color.
+	Outline     bool    // Whether the outlines of closed shapes are drawn, polylines are always drawn.
+	LineWidth   float64 // The width of outlines and polylines in pixels.
+	PointRadius float64 // The radius of point objects in pixels.
+}
+
+// DefaultObjectStyle is the style of newly loaded Resources.
+var DefaultObjectStyle = ObjectStyle{
+	Fill:        true,
+	FillAlpha:   0.5,
+	Outline:     true,
+	LineWidth:   1.0,
+	PointRadius: 3.0,
+}
+
+// ellipseSegments is the number of segments ellipses are approximated with.
+const ellipseSegments = 32
+
+type vec struct {
+	x, y float64
+}
+
+// parsePoints parses the points of a polygon or polyline, which are
+// relative to the object position.
+func parsePoints(points string) ([]vec, error) {
+	var pts []vec
+	for _, field := range strings.Fields(points) {
+		pt := strings.Split(field, ",")
+		if len(pt) != 2 {
+			return nil, errors.Errorf("invalid point '%s'", field)
+		}
+		x, err := strconv.ParseFloat(pt[0], 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid x-axis point")
+		}
+		y, err := strconv.ParseFloat(pt[1], 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid y-axis point")
+		}
+		pts = append(pts, vec{x, y})
+	}
+	return pts, nil
+}
+
+func rectPoints(w, h float64) []vec {
+	return []vec{{0, 0}, {w, 0}, {w, h}, {0, h}}
+}
+
+func ellipsePoints(cx, cy, rx, ry float64) []vec {
+	pts := make([]vec, ellipseSegments)
+	for i := range pts {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / ellipseSegments)
+		pts[i] = vec{cx + rx*cos, cy + ry*sin}
+	}
+	return pts
+}
+
+// shape collects the triangles of an object shape in map pixels.
+type shape struct {
+	vertices []ebiten.Vertex
+	indices  []uint16
+}
+
+// shapeBuilder appends the triangles of filled polygons and lines to a
+// shape. Points are given relative to the object and transformed by geom.
+type shapeBuilder struct {
+	shape *shape
+	geom  ebiten.GeoM
+	r     float32
+	g     float32
+	b     float32
+	a     float32
+}
+
+func newShapeBuilder(s *shape, geom ebiten.GeoM, c color.Color, alpha float64) *shapeBuilder {
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return &shapeBuilder{
+		shape: s,
+		geom:  geom,
+		r:     float32(nc.R) / 0xFF,
+		g:     float32(nc.G) / 0xFF,
+		b:     float32(nc.B) / 0xFF,
+		a:     float32(float64(nc.A) / 0xFF * alpha),
+	}
+}
+
+func (sb *shapeBuilder) vertex(p vec) uint16 {
+	x, y := sb.geom.Apply(p.x, p.y)
+	sb.shape.vertices = append(sb.shape.vertices, ebiten.Vertex{
+		DstX:   float32(x),
+		DstY:   float32(y),
+		SrcX:   1,
+		SrcY:   1,
+		ColorR: sb.r,
+		ColorG: sb.g,
+		ColorB: sb.b,
+		ColorA: sb.a,
+	})
+	return uint16(len(sb.shape.vertices) - 1)
+}
+
+// fill triangulates the polygon by ear clipping, which also handles
+// concave polygons. The polygon must not intersect itself.
+func (sb *shapeBuilder) fill(pts []vec) {
+	if len(pts) < 3 {
+		return
+	}
+	idx := make([]uint16, len(pts))
+	for i, p := range pts {
+		idx[i] = sb.vertex(p)
+	}
+	remaining := make([]int, len(pts))
+	for i := range remaining {
+		remaining[i] = i
+	}
+	// the sign of the area tells the winding of the polygon
+	orientation := 1.0
+	if signedArea(pts) < 0 {
+		orientation = -1.0
+	}
+	for len(remaining) > 3 {
+		found := false
+		for i := range remaining {
+			prev := remaining[(i+len(remaining)-1)%len(remaining)]
+			cur := remaining[i]
+			next := remaining[(i+1)%len(remaining)]
+			if !isEar(pts, remaining, prev, cur, next, orientation) {
+				continue
+			}
+			sb.shape.indices = append(sb.shape.indices, idx[prev], idx[cur], idx[next])
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			found = true
+			break
+		}
+		if !found {
+			break // degenerate polygon, fan the rest
+		}
+	}
+	for i := 1; i+1 < len(remaining); i++ {
+		sb.shape.indices = append(sb.shape.indices, idx[remaining[0]], idx[remaining[i]], idx[remaining[i+1]])
+	}
+}
+
+// stroke draws the lines between the points as quads of the given width.
+func (sb *shapeBuilder) stroke(pts []vec, closed bool, width float64) {
+	n := len(pts)
+	if !closed {
+		n--
+	}
+	for i := 0; i < n; i++ {
+		p, q := pts[i], pts[(i+1)%len(pts)]
+		dx, dy := q.x-p.x, q.y-p.y
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			continue
+		}
+		nx, ny := -dy/l*width/2, dx/l*width/2
+		a := sb.vertex(vec{p.x + nx, p.y + ny})
+		b := sb.vertex(vec{q.x + nx, q.y + ny})
+		c := sb.vertex(vec{q.x - nx, q.y - ny})
+		d := sb.vertex(vec{p.x - nx, p.y - ny})
+		sb.shape.indices = append(sb.shape.indices, a, b, c, a, c, d)
+	}
+}
+
+func signedArea(pts []vec) float64 {
+	area := 0.0
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		area += p.x*q.y - q.x*p.y
+	}
+	return area / 2
+}
+
+func cross(a, b, c vec) float64 {
+	return (b.x-a.x)*(c.y-a.y) - (b.y-a.y)*(c.x-a.x)
+}
+
+// isEar returns true if the triangle prev, cur, next is convex and contains
+// none of the remaining points.
+func isEar(pts []vec, remaining []int, prev, cur, next int, orientation float64) bool {
+	a, b, c := pts[prev], pts[cur], pts[next]
+	if cross(a, b, c)*orientation <= 0 {
+		return false
+	}
+	for _, i := range remaining {
+		if i == prev || i == cur || i == next {
+			continue
+		}
+		p := pts[i]
+		if cross(a, b, p)*orientation >= 0 && cross(b, c, p)*orientation >= 0 && cross(c, a, p)*orientation >= 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package ebitentmx
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func triangleArea(s *shape) float64 {
+	area := 0.0
+	for i := 0; i+2 < len(s.indices); i += 3 {
+		a := s.vertices[s.indices[i]]
+		b := s.vertices[s.indices[i+1]]
+		c := s.vertices[s.indices[i+2]]
+		area += math.Abs(float64((b.DstX-a.DstX)*(c.DstY-a.DstY)-(b.DstY-a.DstY)*(c.DstX-a.DstX))) / 2
+	}
+	return area
+}
+
+func TestParsePoints(t *testing.T) {
+	pts, err := parsePoints("0,0 16,-8.5 32,0")
+	require.NoError(t, err)
+	assert.Equal(t, []vec{{0, 0}, {16, -8.5}, {32, 0}}, pts)
+
+	_, err = parsePoints("0,0 16")
+	assert.Error(t, err)
+	_, err = parsePoints("0,0 a,1")
+	assert.Error(t, err)
+}
+
+func TestFillConcave(t *testing.T) {
+	// an L shape with an area of 3 unit squares, in both windings
+	l := []vec{{0, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 2}, {0, 2}}
+	for _, pts := range [][]vec{l, {l[5], l[4], l[3], l[2], l[1], l[0]}} {
+		s := &shape{}
+		newShapeBuilder(s, ebiten.GeoM{}, color.White, 1.0).fill(pts)
+		assert.Len(t, s.vertices, 6)
+		assert.Len(t, s.indices, 3*4)
+		assert.InDelta(t, 3.0, triangleArea(s), 1e-6)
+	}
+}
+
+func TestStroke(t *testing.T) {
+	s := &shape{}
+	newShapeBuilder(s, ebiten.GeoM{}, color.White, 0.5).stroke(rectPoints(10, 4), true, 2)
+	assert.Len(t, s.indices, 4*6)
+	assert.InDelta(t, 2*(10+4)*2, triangleArea(s), 1e-6)
+	assert.InDelta(t, 0.5, s.vertices[0].ColorA, 1e-6)
+
+	s = &shape{}
+	newShapeBuilder(s, ebiten.GeoM{}, color.White, 1.0).stroke(rectPoints(10, 4), false, 2)
+	assert.Len(t, s.indices, 3*6)
+}
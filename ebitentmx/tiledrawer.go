@@ -1,6 +1,10 @@
 package ebitentmx
 
 import (
+	"image"
+	"math"
+
+	"github.com/elliotmr/tmx"
 	"github.com/hajimehoshi/ebiten"
 	"github.com/pkg/errors"
 )
@@ -8,29 +12,20 @@ import (
 type tileLayerDrawer struct {
 	resources *Resources
 	info      *LayerInfo
-	opts      *ebiten.DrawImageOptions
-	image     *ebiten.Image
+	tiles     []tmx.TileInstance // the cells of the layer bounds row by row
+	margin    image.Rectangle    // how far tile images reach outside of their cell
+	chunkSize int
+	opts      ebiten.DrawImageOptions
 }
 
 func newTileLayerDrawer(resources *Resources, info *LayerInfo) (*tileLayerDrawer, error) {
-	img, _ := ebiten.NewImage(
-		info.w*int(info.mapData.TileWidth),
-		info.h*int(info.mapData.TileHeight),
-		ebiten.FilterNearest,
-	)
-	bounds := img.Bounds()
-	geom := ebiten.GeoM{}
-	geom.Translate(info.offX, info.offY)
-	opts := &ebiten.DrawImageOptions{
-		SourceRect: &bounds,
-		GeoM:       geom,
-		Filter:     ebiten.FilterNearest,
-	}
 	ld := &tileLayerDrawer{
 		resources: resources,
 		info:      info,
-		opts:      opts,
-		image:     img,
+		opts: ebiten.DrawImageOptions{
+			ColorM: info.color,
+			Filter: ebiten.FilterNearest,
+		},
 	}
 
 	return ld, ld.Update()
@@ -44,48 +39,195 @@ func (ld *tileLayerDrawer) Info() *LayerInfo {
 	return ld.info
 }
 
-func (ld *tileLayerDrawer) Image() *ebiten.Image {
-	return ld.image
-}
-
 func (ld *tileLayerDrawer) Update() error {
-	// TODO: draworder
-	iter, err := ld.info.layer.Data.Iter()
-	if err != nil {
-		return errors.Wrap(err, "unable to load layer iterator")
+	ld.resources.chunks.removeLayer(ld)
+	ld.chunkSize = ld.resources.ChunkSize
+	if ld.chunkSize <= 0 {
+		ld.chunkSize = DefaultChunkSize
 	}
-	for iter.Next() {
-		tile := iter.Get()
-		if tile.GID() == 0 {
-			continue
+	ld.tiles = make([]tmx.TileInstance, ld.info.w*ld.info.h)
+	tw := int(ld.info.mapData.TileWidth)
+	th := int(ld.info.mapData.TileHeight)
+	extent := image.Rect(0, 0, tw, th) // the area covered by a cell and its tile image
+	var tileErr error
+	err := ld.info.layer.Data.ForEach(ld.info.w, func(x, y int, tile tmx.TileInstance) {
+		if tileErr != nil {
+			return
 		}
+		cell := ld.info.cell(x, y)
+		tileErr = ld.place(cell, tile, &extent)
+	})
+	ld.margin = image.Rectangle{Min: extent.Min, Max: extent.Max.Sub(image.Pt(tw, th))}
+	if tileErr != nil {
+		return tileErr
+	}
+	return err
+}
+
+// place validates a tile and puts it into a cell, the extent is grown by the
+// area its image covers relative to the cell.
+func (ld *tileLayerDrawer) place(cell int, tile tmx.TileInstance, extent *image.Rectangle) error {
+	if tile.GID() != 0 {
 		tse, exists := ld.resources.entries[tile.GID()]
 		if !exists {
 			return errors.Errorf("tile with gid '%d' does not exist", tile.GID())
 		}
-		cellIndex := int(iter.GetIndex())
-		rect, err := ld.info.TileRect(cellIndex)
+		if _, exists := ld.resources.images[tse.source]; !exists {
+			return errors.Errorf("image source '%v' does not exist", tse.source)
+		}
+		rect, err := ld.tileRect(tile, tse, cell)
 		if err != nil {
 			return errors.Wrap(err, "invalid tile")
 		}
+		tw := int(ld.info.mapData.TileWidth)
+		th := int(ld.info.mapData.TileHeight)
+		*extent = extent.Union(rect.Sub(image.Pt((cell%ld.info.w)*tw, (cell/ld.info.w)*th)))
+	}
+	ld.tiles[cell] = tile
+	return nil
+}
 
-		opts := &ebiten.DrawImageOptions{
-			SourceRect: tse.rect,
-			ColorM:     ld.info.color,
-			GeoM:       calcGeoM(tile, rect),
-			Filter:     ebiten.FilterNearest,
-		}
+// SetTile changes the tile at position (x, y) in map tiles, both in the layer
+// data and in the drawer. Only the chunk containing the tile is rendered
+// again, the position must be within the bounds of the layer.
+func (ld *tileLayerDrawer) SetTile(x, y int, tile tmx.TileInstance) error {
+	if !image.Pt(x, y).In(image.Rect(ld.info.x0, ld.info.y0, ld.info.x0+ld.info.w, ld.info.y0+ld.info.h)) {
+		return errors.Errorf("tile (%d, %d) outside of the layer bounds", x, y)
+	}
+	cell := ld.info.cell(x, y)
+	tw := int(ld.info.mapData.TileWidth)
+	th := int(ld.info.mapData.TileHeight)
+	extent := image.Rectangle{Min: ld.margin.Min, Max: ld.margin.Max.Add(image.Pt(tw, th))}
+	old := ld.tiles[cell]
+	err := ld.place(cell, tile, &extent)
+	if err != nil {
+		return errors.Wrap(err, "unable to set tile")
+	}
+	err = ld.info.layer.Data.SetTile(ld.info.w, x, y, tile)
+	if err != nil {
+		ld.tiles[cell] = old
+		return errors.Wrap(err, "unable to set tile")
+	}
+	ld.margin = image.Rectangle{Min: extent.Min, Max: extent.Max.Sub(image.Pt(tw, th))}
+	n := ld.chunkSize
+	ld.resources.chunks.remove(chunkKey{layer: ld, x: floorDiv(x, n), y: floorDiv(y, n)})
+	return nil
+}
 
-		srcImage, exists := ld.resources.images[tse.source]
-		if !exists {
-			return errors.Errorf("image source '%v' does not exist", tse)
-		}
+// tileRect returns the rect a tile is drawn to, taking the tileset offset
+// and render size into account.
+func (ld *tileLayerDrawer) tileRect(tile tmx.TileInstance, tse tileSetEntry, cell int) (image.Rectangle, error) {
+	w, h := tse.size(tile)
+	offX, offY := tse.offset()
+	rw, rh, padX, padY := tse.tileSet.RenderSize(
+		float64(w), float64(h),
+		float64(ld.info.mapData.TileWidth),
+		float64(ld.info.mapData.TileHeight),
+	)
+	return ld.info.TileImageRect(
+		cell,
+		int(math.Round(rw)), int(math.Round(rh)),
+		offX+int(math.Round(padX)), offY-int(math.Round(padY)),
+	)
+}
 
-		ld.image.DrawImage(srcImage, opts)
+// chunk returns the render chunk at (cx, cy), it is rendered if it is not
+// cached.
+func (ld *tileLayerDrawer) chunk(cx, cy int) (*renderChunk, error) {
+	key := chunkKey{layer: ld, x: cx, y: cy}
+	if rc, exists := ld.resources.chunks.get(key); exists {
+		return rc, nil
+	}
+	rc, err := ld.renderChunk(key)
+	if err != nil {
+		return nil, err
 	}
-	return errors.Wrap(iter.Error(), "unable to iterate through layer")
+	ld.resources.chunks.put(rc, ld.resources.ChunkBudget)
+	return rc, nil
 }
 
-func (ld *tileLayerDrawer) Draw(image *ebiten.Image) error {
-	return errors.Wrap(image.DrawImage(ld.image, ld.opts), "unable to draw layer")
+// renderChunk renders the tiles of a chunk to an image just large enough to
+// hold them.
+func (ld *tileLayerDrawer) renderChunk(key chunkKey) (*renderChunk, error) {
+	n := ld.chunkSize
+	cells := image.Rect(
+		key.x*n-ld.info.x0, key.y*n-ld.info.y0,
+		(key.x+1)*n-ld.info.x0, (key.y+1)*n-ld.info.y0,
+	).Intersect(image.Rect(0, 0, ld.info.w, ld.info.h))
+	rc := &renderChunk{key: key}
+	// TODO: draworder
+	var cellList []int
+	var rects []image.Rectangle
+	for y := cells.Min.Y; y < cells.Max.Y; y++ {
+		for x := cells.Min.X; x < cells.Max.X; x++ {
+			cell := y*ld.info.w + x
+			tile := ld.tiles[cell]
+			if tile.GID() == 0 {
+				continue
+			}
+			rect, _ := ld.tileRect(tile, ld.resources.entries[tile.GID()], cell)
+			rc.rect = rc.rect.Union(rect)
+			cellList = append(cellList, cell)
+			rects = append(rects, rect)
+		}
+	}
+	if rc.rect.Empty() {
+		return rc, nil
+	}
+	img, err := ebiten.NewImage(rc.rect.Dx(), rc.rect.Dy(), ebiten.FilterNearest)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create chunk image")
+	}
+	rc.image = img
+	rc.size = rc.rect.Dx() * rc.rect.Dy() * 4
+	opts := ebiten.DrawImageOptions{Filter: ebiten.FilterNearest}
+	for i, cell := range cellList {
+		tile := ld.tiles[cell]
+		tse := ld.resources.entries[tile.GID()]
+		opts.GeoM = calcGeoM(tile, *tse.rect, rects[i])
+		opts.GeoM.Translate(-float64(rc.rect.Min.X), -float64(rc.rect.Min.Y))
+		opts.SourceRect = tse.rect
+		err := img.DrawImage(ld.resources.images[tse.source], &opts)
+		if err != nil {
+			rc.dispose()
+			return nil, errors.Wrap(err, "unable to render chunk")
+		}
+	}
+	return rc, nil
+}
+
+// Draw draws the chunks intersecting the visible bounds of the view directly
+// to the destination image. Chunks are aligned to multiples of the chunk
+// size in map tiles, like the chunks of infinite maps.
+func (ld *tileLayerDrawer) Draw(dst *ebiten.Image, view View) error {
+	cells := image.Rect(0, 0, ld.info.w, ld.info.h)
+	if r, ok := view.visible(ld.info); ok {
+		cells = ld.info.cellRange(r, ld.margin)
+	}
+	if cells.Empty() {
+		return nil
+	}
+	n := ld.chunkSize
+	cells = cells.Add(image.Pt(ld.info.x0, ld.info.y0))
+	originX := float64(ld.info.x0*int(ld.info.mapData.TileWidth)) + ld.info.offX
+	originY := float64(ld.info.y0*int(ld.info.mapData.TileHeight)) + ld.info.offY
+	for cy := floorDiv(cells.Min.Y, n); cy*n < cells.Max.Y; cy++ {
+		for cx := floorDiv(cells.Min.X, n); cx*n < cells.Max.X; cx++ {
+			rc, err := ld.chunk(cx, cy)
+			if err != nil {
+				return errors.Wrap(err, "unable to draw layer")
+			}
+			if rc.image == nil {
+				continue
+			}
+			geom := ebiten.GeoM{}
+			geom.Translate(float64(rc.rect.Min.X)+originX, float64(rc.rect.Min.Y)+originY)
+			ld.opts.GeoM = view.geoM(ld.info, geom)
+			err = dst.DrawImage(rc.image, &ld.opts)
+			if err != nil {
+				return errors.Wrap(err, "unable to draw layer")
+			}
+		}
+	}
+	return nil
 }
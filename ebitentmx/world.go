@@ -0,0 +1,207 @@
+package ebitentmx
+
+import (
+	"context"
+	"image"
+	"path/filepath"
+	"sync"
+
+	"github.com/elliotmr/tmx"
+	"github.com/hajimehoshi/ebiten"
+	"github.com/pkg/errors"
+)
+
+// WorldDrawer draws the maps of a tmx.World around the camera. Only the maps
+// within Radius of the camera are kept loaded, each with its own Resources
+// and Drawer. Maps coming into range are loaded and their images decoded in
+// the background, the ebiten images are created by Update on the calling
+// goroutine. Maps going out of range are unloaded and their images disposed.
+// World coordinates are the pixel coordinates of the world file, so a View
+// from a Camera can be used as is.
+type WorldDrawer struct {
+	Radius    float64            // The distance in world pixels from the camera within which maps are loaded.
+	Configure func(r *Resources) // Called on new resources before the drawer is created, e.g. to register fonts (optional).
+
+	world   *tmx.World
+	active  map[*tmx.WorldMap]*worldEntry
+	loading map[*tmx.WorldMap]bool
+	failed  map[*tmx.WorldMap]bool
+
+	mu   sync.Mutex
+	done []worldLoad
+}
+
+// worldEntry is a loaded map of the world.
+type worldEntry struct {
+	resources *Resources
+	drawer    Drawer
+}
+
+// worldLoad is the result of loading a map in the background.
+type worldLoad struct {
+	wm      *tmx.WorldMap
+	mapData *tmx.Map
+	images  map[string]image.Image
+	err     error
+}
+
+// NewWorldDrawer creates a drawer for the world, no maps are loaded until
+// the first call to Update.
+func NewWorldDrawer(world *tmx.World, radius float64, configure func(r *Resources)) *WorldDrawer {
+	return &WorldDrawer{
+		Radius:    radius,
+		Configure: configure,
+		world:     world,
+		active:    make(map[*tmx.WorldMap]*worldEntry),
+		loading:   make(map[*tmx.WorldMap]bool),
+		failed:    make(map[*tmx.WorldMap]bool),
+	}
+}
+
+// Update moves the camera to (cameraX, cameraY) in world pixels. It starts
+// loading the maps that came into range, activates the maps that finished
+// loading and unloads the maps that went out of range. It must be called
+// between frames. Maps are found by their size in the world and are only
+// loaded in the background. Maps that failed to load are returned as a
+// tmx.ErrorList, they are not tried again until they have been out of range.
+func (wd *WorldDrawer) Update(cameraX, cameraY float64) error {
+	near := wd.world.MapsNear(cameraX, cameraY, wd.Radius)
+	wanted := make(map[*tmx.WorldMap]bool, len(near))
+	for _, wm := range near {
+		wanted[wm] = true
+		if wd.active[wm] == nil && !wd.loading[wm] && !wd.failed[wm] {
+			wd.loading[wm] = true
+			go wd.load(wm)
+		}
+	}
+
+	wd.mu.Lock()
+	done := wd.done
+	wd.done = nil
+	wd.mu.Unlock()
+	var el tmx.ErrorList
+	for _, l := range done {
+		delete(wd.loading, l.wm)
+		if !wanted[l.wm] {
+			l.wm.Unload()
+			continue
+		}
+		entry, err := wd.activate(l)
+		if err != nil {
+			l.wm.Unload()
+			wd.failed[l.wm] = true
+			el = append(el, errors.Wrapf(err, "unable to load world map '%s'", l.wm.FileName))
+			continue
+		}
+		wd.active[l.wm] = entry
+	}
+
+	for wm, entry := range wd.active {
+		if !wanted[wm] {
+			entry.resources.dispose()
+			delete(wd.active, wm)
+			wm.Unload()
+		}
+	}
+	for wm := range wd.failed {
+		if !wanted[wm] {
+			delete(wd.failed, wm)
+		}
+	}
+	return el.Err()
+}
+
+// load loads a map and decodes its images, it runs in the background.
+func (wd *WorldDrawer) load(wm *tmx.WorldMap) {
+	l := worldLoad{wm: wm}
+	l.mapData, l.err = wm.Map()
+	if l.err == nil {
+		l.images, l.err = tmx.DecodeImages(context.Background(), filepath.Dir(wm.Path()), l.mapData.Images(), nil)
+	}
+	wd.mu.Lock()
+	wd.done = append(wd.done, l)
+	wd.mu.Unlock()
+}
+
+// activate creates the resources and the drawer of a loaded map.
+func (wd *WorldDrawer) activate(l worldLoad) (*worldEntry, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	res, err := newResources(l.mapData, filepath.Dir(l.wm.Path()), l.images)
+	if err != nil {
+		return nil, err
+	}
+	if wd.Configure != nil {
+		wd.Configure(res)
+	}
+	d, err := NewRootDrawer(res, l.mapData)
+	if err != nil {
+		res.dispose()
+		return nil, errors.Wrap(err, "unable to create drawer")
+	}
+	return &worldEntry{resources: res, drawer: d}, nil
+}
+
+// Draw draws all active maps in the order of the world, each moved by its
+// position in the world. The view is given in world pixels.
+func (wd *WorldDrawer) Draw(img *ebiten.Image, view View) error {
+	for _, wm := range wd.world.Maps {
+		entry := wd.active[wm]
+		if entry == nil {
+			continue
+		}
+		x, y := float64(wm.X), float64(wm.Y)
+		local := View{
+			CameraX: view.CameraX - x,
+			CameraY: view.CameraY - y,
+			Bounds:  view.Bounds.Sub(image.Pt(wm.X, wm.Y)),
+		}
+		local.GeoM.Translate(x, y)
+		local.GeoM.Concat(view.GeoM)
+		err := entry.drawer.Draw(img, local)
+		if err != nil {
+			return errors.Wrapf(err, "unable to draw world map '%s'", wm.FileName)
+		}
+	}
+	return nil
+}
+
+// Active returns the maps which are loaded and drawn, in the order of the
+// world.
+func (wd *WorldDrawer) Active() []*tmx.WorldMap {
+	var maps []*tmx.WorldMap
+	for _, wm := range wd.world.Maps {
+		if wd.active[wm] != nil {
+			maps = append(maps, wm)
+		}
+	}
+	return maps
+}
+
+// Drawer returns the root drawer of a map, or nil if it is not active.
+func (wd *WorldDrawer) Drawer(wm *tmx.WorldMap) Drawer {
+	if entry := wd.active[wm]; entry != nil {
+		return entry.drawer
+	}
+	return nil
+}
+
+// Resources returns the resources of a map, or nil if it is not active.
+func (wd *WorldDrawer) Resources(wm *tmx.WorldMap) *Resources {
+	if entry := wd.active[wm]; entry != nil {
+		return entry.resources
+	}
+	return nil
+}
+
+// Close unloads all active maps and disposes their images. Maps still
+// loading in the background are not waited for.
+func (wd *WorldDrawer) Close() {
+	for wm, entry := range wd.active {
+		entry.resources.dispose()
+		wm.Unload()
+	}
+	wd.active = make(map[*tmx.WorldMap]*worldEntry)
+	wd.failed = make(map[*tmx.WorldMap]bool)
+}
@@ -0,0 +1,338 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Anchor is the point of a map that stays in place when it is resized, given
+// as fractions of the map size from the top-left corner.
+type Anchor struct {
+	X, Y float64
+}
+
+// Anchors of the corners, edges and center of a map.
+var (
+	AnchorTopLeft     = Anchor{0, 0}
+	AnchorTop         = Anchor{0.5, 0}
+	AnchorTopRight    = Anchor{1, 0}
+	AnchorLeft        = Anchor{0, 0.5}
+	AnchorCenter      = Anchor{0.5, 0.5}
+	AnchorRight       = Anchor{1, 0.5}
+	AnchorBottomLeft  = Anchor{0, 1}
+	AnchorBottom      = Anchor{0.5, 1}
+	AnchorBottomRight = Anchor{1, 1}
+)
+
+// NewTileLayer creates an empty tile layer. Its size and data are set when
+// it is added to a map.
+func NewTileLayer(name string) *Layer {
+	return &Layer{XMLName: xml.Name{Local: "layer"}, Name: name}
+}
+
+// NewObjectGroup creates an empty object group.
+func NewObjectGroup(name string) *Layer {
+	return &Layer{XMLName: xml.Name{Local: "objectgroup"}, Name: name}
+}
+
+// NewImageLayer creates an image layer showing img.
+func NewImageLayer(name string, img *Image) *Layer {
+	return &Layer{XMLName: xml.Name{Local: "imagelayer"}, Name: name, Image: img}
+}
+
+// walkLayers calls fn for every layer of the map, parents before their
+// children. The parent is nil for top-level layers.
+func (m *Map) walkLayers(fn func(l, parent *Layer)) {
+	var walk func(layers []*Layer, parent *Layer)
+	walk = func(layers []*Layer, parent *Layer) {
+		for _, l := range layers {
+			fn(l, parent)
+			walk(l.Layers, l)
+		}
+	}
+	walk(m.Layers, nil)
+}
+
+// FindLayer returns the layer with the given ID, or nil if there is none.
+func (m *Map) FindLayer(id uint32) *Layer {
+	var found *Layer
+	m.walkLayers(func(l, parent *Layer) {
+		if found == nil && l.ID == id {
+			found = l
+		}
+	})
+	return found
+}
+
+// FindObject returns the object with the given ID and the object group it
+// belongs to, or nil if there is none.
+func (m *Map) FindObject(id uint32) (*Object, *Layer) {
+	var obj *Object
+	var group *Layer
+	m.walkLayers(func(l, parent *Layer) {
+		for _, o := range l.Objects {
+			if obj == nil && o.ID == id {
+				obj, group = o, l
+			}
+		}
+	})
+	return obj, group
+}
+
+// children returns the list of layers of a group, or the top-level layers
+// of the map if parent is nil.
+func (m *Map) children(parent *Layer) *[]*Layer {
+	if parent == nil {
+		return &m.Layers
+	}
+	return &parent.Layers
+}
+
+// parentOf returns the parent and the index of a layer within it.
+func (m *Map) parentOf(layer *Layer) (parent *Layer, index int, ok bool) {
+	for i, l := range m.Layers {
+		if l == layer {
+			return nil, i, true
+		}
+	}
+	m.walkLayers(func(l, p *Layer) {
+		for i, child := range l.Layers {
+			if child == layer {
+				parent, index, ok = l, i, true
+			}
+		}
+	})
+	return parent, index, ok
+}
+
+// groupOf returns the object group holding an object and the index of the
+// object within it, or nil if the object is not part of the map.
+func (m *Map) groupOf(obj *Object) (group *Layer, index int) {
+	m.walkLayers(func(l, parent *Layer) {
+		for i, o := range l.Objects {
+			if group == nil && o == obj {
+				group, index = l, i
+			}
+		}
+	})
+	return group, index
+}
+
+// nextLayerID returns an unused layer ID and increments NextLayerID. Maps
+// saved before Tiled 1.2 have no layer IDs, the counter then starts after
+// the largest ID in use.
+func (m *Map) nextLayerID() uint32 {
+	if m.NextLayerID == 0 {
+		m.NextLayerID = 1
+		m.walkLayers(func(l, parent *Layer) {
+			if l.ID >= m.NextLayerID {
+				m.NextLayerID = l.ID + 1
+			}
+		})
+	}
+	id := m.NextLayerID
+	m.NextLayerID++
+	return id
+}
+
+// nextObjectID returns an unused object ID and increments NextObjectId.
+func (m *Map) nextObjectID() uint32 {
+	if m.NextObjectId == 0 {
+		m.NextObjectId = 1
+		m.walkLayers(func(l, parent *Layer) {
+			for _, obj := range l.Objects {
+				if obj.ID >= m.NextObjectId {
+					m.NextObjectId = obj.ID + 1
+				}
+			}
+		})
+	}
+	id := m.NextObjectId
+	m.NextObjectId++
+	return id
+}
+
+// insert inserts a layer into a list of layers at index, an index out of
+// range appends the layer.
+func insert(layers []*Layer, index int, layer *Layer) []*Layer {
+	if index < 0 || index >= len(layers) {
+		return append(layers, layer)
+	}
+	layers = append(layers, nil)
+	copy(layers[index+1:], layers[index:])
+	layers[index] = layer
+	return layers
+}
+
+// AddLayer inserts a layer into a group at index, or into the top-level
+// layers if parent is nil. An index out of range appends the layer, which
+// is drawn above the layers before it. The layer and all layers and objects
+// within it get new IDs. Tile layers of fixed-size maps without data are
+// given the size of the map and empty csv data.
+func (m *Map) AddLayer(parent *Layer, index int, layer *Layer) error {
+	if parent != nil && parent.XMLName.Local != "group" {
+		return errors.Errorf("layer '%s' is not a group", parent.Name)
+	}
+	if _, _, exists := m.parentOf(layer); exists {
+		return errors.Errorf("layer '%s' is already part of the map", layer.Name)
+	}
+	if parent != nil {
+		if _, _, exists := m.parentOf(parent); !exists {
+			return errors.Errorf("group '%s' is not part of the map", parent.Name)
+		}
+	}
+	var prepare func(l *Layer) error
+	prepare = func(l *Layer) error {
+		l.ID = m.nextLayerID()
+		for _, obj := range l.Objects {
+			obj.ID = m.nextObjectID()
+		}
+		if l.XMLName.Local == "layer" && l.Data == nil && (m.Infinite == nil || *m.Infinite == 0) {
+			w, h := m.Width, m.Height
+			l.Width, l.Height = &w, &h
+			csv := "csv"
+			l.Data = &Data{Encoding: &csv}
+			err := l.Data.Encode(int(w), make([]TileInstance, w*h))
+			if err != nil {
+				return err
+			}
+		}
+		for _, child := range l.Layers {
+			err := prepare(child)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := prepare(layer)
+	if err != nil {
+		return errors.Wrap(err, "unable to add layer")
+	}
+	layers := m.children(parent)
+	*layers = insert(*layers, index, layer)
+	return nil
+}
+
+// AddGroup creates an empty group layer and adds it like AddLayer.
+func (m *Map) AddGroup(parent *Layer, index int, name string) (*Layer, error) {
+	group := &Layer{XMLName: xml.Name{Local: "group"}, Name: name}
+	err := m.AddLayer(parent, index, group)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// RemoveLayer removes a layer, and all layers within it, from the map. The
+// IDs of removed layers are not reused.
+func (m *Map) RemoveLayer(layer *Layer) error {
+	parent, index, exists := m.parentOf(layer)
+	if !exists {
+		return errors.Errorf("layer '%s' is not part of the map", layer.Name)
+	}
+	layers := m.children(parent)
+	*layers = append((*layers)[:index], (*layers)[index+1:]...)
+	return nil
+}
+
+// MoveLayer moves a layer to index within a group, or within the top-level
+// layers if parent is nil. The index refers to the position after the layer
+// has been taken out of its current parent, an index out of range moves the
+// layer to the top.
+func (m *Map) MoveLayer(layer, parent *Layer, index int) error {
+	if parent != nil && parent.XMLName.Local != "group" {
+		return errors.Errorf("layer '%s' is not a group", parent.Name)
+	}
+	for p := parent; p != nil; p, _, _ = m.parentOf(p) {
+		if p == layer {
+			return errors.Errorf("unable to move layer '%s' into itself", layer.Name)
+		}
+	}
+	if parent != nil {
+		if _, _, exists := m.parentOf(parent); !exists {
+			return errors.Errorf("group '%s' is not part of the map", parent.Name)
+		}
+	}
+	err := m.RemoveLayer(layer)
+	if err != nil {
+		return err
+	}
+	layers := m.children(parent)
+	*layers = insert(*layers, index, layer)
+	return nil
+}
+
+// AddObject adds an object to an object group and assigns it a new ID.
+func (m *Map) AddObject(group *Layer, obj *Object) error {
+	if group.XMLName.Local != "objectgroup" {
+		return errors.Errorf("layer '%s' is not an object group", group.Name)
+	}
+	if _, _, exists := m.parentOf(group); !exists {
+		return errors.Errorf("object group '%s' is not part of the map", group.Name)
+	}
+	obj.ID = m.nextObjectID()
+	group.Objects = append(group.Objects, obj)
+	return nil
+}
+
+// RemoveObject removes the object with the given ID from its object group.
+// The ID is not reused.
+func (m *Map) RemoveObject(id uint32) error {
+	obj, group := m.FindObject(id)
+	if obj == nil {
+		return errors.Errorf("object %d does not exist", id)
+	}
+	for i, o := range group.Objects {
+		if o == obj {
+			group.Objects = append(group.Objects[:i], group.Objects[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// ResizeMap changes the size of a fixed-size map in tiles. The anchor is the
+// point of the map that stays in place, the contents of the tile layers, the
+// objects and the image layers are moved along with it. Tiles outside of the
+// new size are lost, objects are kept even if they end up outside of the map.
+func (m *Map) ResizeMap(width, height uint32, anchor Anchor) error {
+	if m.Infinite != nil && *m.Infinite != 0 {
+		return errors.New("infinite maps can not be resized")
+	}
+	dx := int(math.Round((float64(width) - float64(m.Width)) * anchor.X))
+	dy := int(math.Round((float64(height) - float64(m.Height)) * anchor.Y))
+	return m.reframe(width, height, dx, dy)
+}
+
+// reframe resizes the map to width x height tiles and moves its contents by
+// (dx, dy) tiles. Image layers are moved by changing their offsets, the
+// offsets of other layers are kept as their contents are moved.
+func (m *Map) reframe(width, height uint32, dx, dy int) error {
+	move := func(x, y int) (int, int) { return x + dx, y + dy }
+	err := m.transformTiles(int(width), int(height), move, identity)
+	if err != nil {
+		return errors.Wrap(err, "unable to resize tile layers")
+	}
+	shiftX, shiftY := float64(dx)*float64(m.TileWidth), float64(dy)*float64(m.TileHeight)
+	m.walkLayers(func(l, parent *Layer) {
+		for _, obj := range l.Objects {
+			obj.X += shiftX
+			obj.Y += shiftY
+		}
+		if l.XMLName.Local == "imagelayer" && (dx != 0 || dy != 0) {
+			x, y := shiftX, shiftY
+			if l.OffsetX != nil {
+				x += *l.OffsetX
+			}
+			if l.OffsetY != nil {
+				y += *l.OffsetY
+			}
+			l.OffsetX, l.OffsetY = &x, &y
+		}
+	})
+	m.Width, m.Height = width, height
+	return nil
+}
@@ -0,0 +1,110 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditLayers(t *testing.T) {
+	m := &Map{Width: 4, Height: 3, TileWidth: 16, TileHeight: 16}
+	ground := NewTileLayer("ground")
+	require.NoError(t, m.AddLayer(nil, -1, ground))
+	assert.EqualValues(t, 1, ground.ID)
+	assert.EqualValues(t, 4, *ground.Width)
+	tiles, err := ground.Data.Tiles()
+	require.NoError(t, err)
+	assert.Len(t, tiles, 12)
+
+	group, err := m.AddGroup(nil, -1, "things")
+	require.NoError(t, err)
+	objects := NewObjectGroup("objects")
+	objects.Objects = []*Object{{Name: "a"}, {Name: "b"}}
+	require.NoError(t, m.AddLayer(group, 0, objects))
+	assert.EqualValues(t, 3, objects.ID)
+	assert.EqualValues(t, 4, m.NextLayerID)
+	assert.EqualValues(t, 1, objects.Objects[0].ID)
+	assert.EqualValues(t, 2, objects.Objects[1].ID)
+	assert.Equal(t, objects, m.FindLayer(3))
+
+	assert.Error(t, m.AddLayer(nil, 0, ground), "layers can only be added once")
+	assert.Error(t, m.AddLayer(ground, 0, NewTileLayer("x")), "only groups have children")
+	assert.Error(t, m.MoveLayer(group, group, 0))
+
+	require.NoError(t, m.MoveLayer(objects, nil, 0))
+	assert.Equal(t, []*Layer{objects, ground, group}, m.Layers)
+	assert.Empty(t, group.Layers)
+	require.NoError(t, m.MoveLayer(ground, group, -1))
+	assert.Equal(t, []*Layer{ground}, group.Layers)
+
+	require.NoError(t, m.RemoveLayer(group))
+	assert.Equal(t, []*Layer{objects}, m.Layers)
+	assert.Nil(t, m.FindLayer(1))
+	assert.Error(t, m.RemoveLayer(group))
+
+	// IDs of removed layers are never reused
+	require.NoError(t, m.AddLayer(nil, -1, NewTileLayer("again")))
+	assert.EqualValues(t, 4, m.Layers[1].ID)
+
+	// the edited map is written with the right element names
+	data, err := xml.Marshal(m)
+	require.NoError(t, err)
+	loaded := &Map{}
+	require.NoError(t, xml.Unmarshal(data, loaded))
+	require.Len(t, loaded.Layers, 2)
+	assert.Equal(t, "objectgroup", loaded.Layers[0].XMLName.Local)
+	assert.Equal(t, "layer", loaded.Layers[1].XMLName.Local)
+}
+
+func TestEditObjects(t *testing.T) {
+	m := &Map{NextObjectId: 5}
+	group := NewObjectGroup("objects")
+	require.NoError(t, m.AddLayer(nil, -1, group))
+	obj := &Object{Name: "door"}
+	require.NoError(t, m.AddObject(group, obj))
+	assert.EqualValues(t, 5, obj.ID)
+	assert.EqualValues(t, 6, m.NextObjectId)
+	assert.Error(t, m.AddObject(NewObjectGroup("detached"), &Object{}))
+
+	found, layer := m.FindObject(5)
+	assert.Equal(t, obj, found)
+	assert.Equal(t, group, layer)
+	require.NoError(t, m.RemoveObject(5))
+	assert.Empty(t, group.Objects)
+	assert.Error(t, m.RemoveObject(5))
+}
+
+func TestResizeMap(t *testing.T) {
+	m := &Map{Width: 3, Height: 2, TileWidth: 16, TileHeight: 8}
+	layer := NewTileLayer("ground")
+	require.NoError(t, m.AddLayer(nil, -1, layer))
+	require.NoError(t, layer.Data.Encode(3, []TileInstance{1, 2, 3, 4, 5, 6}))
+	group := NewObjectGroup("objects")
+	require.NoError(t, m.AddLayer(nil, -1, group))
+	obj := &Object{X: 4, Y: 4}
+	require.NoError(t, m.AddObject(group, obj))
+
+	require.NoError(t, m.ResizeMap(5, 4, AnchorCenter))
+	assert.EqualValues(t, 5, m.Width)
+	assert.EqualValues(t, 4, *layer.Height)
+	tiles, err := layer.Data.Tiles()
+	require.NoError(t, err)
+	assert.Equal(t, []TileInstance{
+		0, 0, 0, 0, 0,
+		0, 1, 2, 3, 0,
+		0, 4, 5, 6, 0,
+		0, 0, 0, 0, 0,
+	}, tiles)
+	assert.Equal(t, 20.0, obj.X)
+	assert.Equal(t, 12.0, obj.Y)
+
+	require.NoError(t, m.ResizeMap(2, 1, AnchorBottomRight))
+	tiles, err = layer.Data.Tiles()
+	require.NoError(t, err)
+	assert.Equal(t, []TileInstance{0, 0}, tiles)
+
+	infinite := 1
+	assert.Error(t, (&Map{Infinite: &infinite}).ResizeMap(1, 1, AnchorTopLeft))
+}
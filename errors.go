@@ -0,0 +1,33 @@
+package tmx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorList collects the errors of an operation that does not stop at the
+// first failure, such as loading all the images of a map.
+type ErrorList []error
+
+// Error implements the error interface.
+func (el ErrorList) Error() string {
+	switch len(el) {
+	case 0:
+		return "no errors"
+	case 1:
+		return el[0].Error()
+	}
+	msgs := make([]string, len(el))
+	for i, err := range el {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors occurred: %s", len(el), strings.Join(msgs, "; "))
+}
+
+// Err returns nil if the list is empty, otherwise it returns the list.
+func (el ErrorList) Err() error {
+	if len(el) == 0 {
+		return nil
+	}
+	return el
+}
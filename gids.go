@@ -0,0 +1,283 @@
+package tmx
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Span returns the number of global tile IDs reserved for the tileset. For
+// image collection tilesets the local IDs may have gaps, the span then
+// reaches up to the largest ID.
+func (ts *TileSet) Span() uint32 {
+	span := ts.TileCount
+	for _, tile := range ts.Tiles {
+		if tile.ID+1 > span {
+			span = tile.ID + 1
+		}
+	}
+	return span
+}
+
+// TileSetFor returns the tileset the global tile ID belongs to, or nil if
+// there is none.
+func (m *Map) TileSetFor(gid uint32) *TileSet {
+	var found *TileSet
+	for _, ts := range m.TileSets {
+		if ts.FirstGID <= gid && (found == nil || ts.FirstGID > found.FirstGID) {
+			found = ts
+		}
+	}
+	if found == nil || gid-found.FirstGID >= found.Span() {
+		return nil
+	}
+	return found
+}
+
+// tileBlock holds the decoded tiles of fixed-size layer data or of a chunk.
+type tileBlock struct {
+	layer *Layer
+	chunk *Chunk // nil for fixed-size data
+	width int
+	tiles []TileInstance
+
+	tileData []TileData // encoded tiles
+	data     []byte
+}
+
+// decodeTiles decodes the tiles of all tile layers. Nothing is changed, so
+// the tiles can be modified and encoded once all layers have been decoded.
+func (m *Map) decodeTiles() ([]tileBlock, error) {
+	var blocks []tileBlock
+	var err error
+	m.walkLayers(func(l, parent *Layer) {
+		if err != nil || l.Data == nil {
+			return
+		}
+		if len(l.Data.Chunks) == 0 {
+			width := int(m.Width)
+			if l.Width != nil {
+				width = int(*l.Width)
+			}
+			var tiles []TileInstance
+			tiles, err = l.Data.Tiles()
+			if err != nil {
+				err = errors.Wrapf(err, "unable to decode layer '%s'", l.Name)
+				return
+			}
+			blocks = append(blocks, tileBlock{layer: l, width: width, tiles: tiles})
+			return
+		}
+		for i := range l.Data.Chunks {
+			c := &l.Data.Chunks[i]
+			var iter TileIterator
+			iter, err = l.Data.ChunkIter(c)
+			if err != nil {
+				err = errors.Wrapf(err, "unable to decode layer '%s'", l.Name)
+				return
+			}
+			tiles := make([]TileInstance, c.Width*c.Height)
+			for iter.Next() {
+				if j := int(iter.GetIndex()); j < len(tiles) {
+					tiles[j] = iter.Get()
+				}
+			}
+			if err = iter.Error(); err != nil {
+				err = errors.Wrapf(err, "unable to decode chunk (%v, %v) of layer '%s'", c.X, c.Y, l.Name)
+				return
+			}
+			blocks = append(blocks, tileBlock{layer: l, chunk: c, width: c.Width, tiles: tiles})
+		}
+	})
+	return blocks, err
+}
+
+// encode encodes the tiles in the encoding of the layer data. The layer is
+// only changed by set, so that all blocks can be encoded first.
+func (b *tileBlock) encode() error {
+	var err error
+	b.tileData, b.data, err = encodeTileData(b.layer.Data.Encoding, b.layer.Data.Compression, b.width, b.tiles)
+	return err
+}
+
+// set replaces the layer data or chunk with the encoded tiles.
+func (b *tileBlock) set() {
+	if b.chunk == nil {
+		b.layer.Data.TileData, b.layer.Data.Data = b.tileData, b.data
+		b.layer.Data.tiles = nil
+		return
+	}
+	b.chunk.TileData, b.chunk.Data = b.tileData, b.data
+}
+
+// remapGIDs replaces every global tile ID on tile layers and tile objects
+// with the result of fn, keeping the flip flags. Tile objects whose ID is
+// replaced with 0 lose their tile. The map is left unchanged if a layer can
+// not be encoded.
+func (m *Map) remapGIDs(fn func(gid uint32) uint32) error {
+	blocks, err := m.decodeTiles()
+	if err != nil {
+		return err
+	}
+	remap := func(tile TileInstance) TileInstance {
+		gid := fn(tile.GID())
+		if gid == 0 {
+			return 0
+		}
+		return TileInstance(uint32(tile)&^GIDMask | gid)
+	}
+	for i := range blocks {
+		b := &blocks[i]
+		for j, tile := range b.tiles {
+			b.tiles[j] = remap(tile)
+		}
+		err = b.encode()
+		if err != nil {
+			return errors.Wrapf(err, "unable to encode layer '%s'", b.layer.Name)
+		}
+	}
+	for i := range blocks {
+		blocks[i].set()
+	}
+	m.walkLayers(func(l, parent *Layer) {
+		for _, obj := range l.Objects {
+			if obj.GID != nil {
+				obj.GID = tileGID(remap(TileInstance(*obj.GID)))
+			}
+		}
+	})
+	return nil
+}
+
+// tileGID returns the gid attribute of a tile object showing the tile, or
+// nil if it is empty.
+func tileGID(tile TileInstance) *uint32 {
+	if tile.GID() == 0 {
+		return nil
+	}
+	gid := uint32(tile)
+	return &gid
+}
+
+// setTileSets replaces the tilesets of the map with sets and assigns their
+// first global IDs in order. The tiles of every previous tileset are moved
+// to the tileset target returns for it, keeping their local IDs. Tiles of
+// tilesets without a target, or whose local ID is outside of the target, are
+// cleared.
+func (m *Map) setTileSets(sets []*TileSet, target func(ts *TileSet) *TileSet) error {
+	type oldSet struct {
+		ts    *TileSet
+		first uint32
+	}
+	var old []oldSet
+	for _, ts := range m.TileSets {
+		old = append(old, oldSet{ts, ts.FirstGID})
+	}
+	firsts := make([]uint32, len(sets))
+	first := uint32(1)
+	for i, ts := range sets {
+		firsts[i] = first
+		first += ts.Span()
+	}
+	newFirst := func(ts *TileSet) (uint32, bool) {
+		for i, s := range sets {
+			if s == ts {
+				return firsts[i], true
+			}
+		}
+		return 0, false
+	}
+	err := m.remapGIDs(func(gid uint32) uint32 {
+		var from *oldSet
+		for i := range old {
+			if old[i].first <= gid && (from == nil || old[i].first > from.first) {
+				from = &old[i]
+			}
+		}
+		if from == nil {
+			return 0
+		}
+		local := gid - from.first
+		to := target(from.ts)
+		if to == nil || local >= to.Span() {
+			return 0
+		}
+		first, ok := newFirst(to)
+		if !ok {
+			return 0
+		}
+		return first + local
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to remap tiles")
+	}
+	for i, ts := range sets {
+		ts.FirstGID = firsts[i]
+	}
+	m.TileSets = sets
+	return nil
+}
+
+// indexOf returns the index of a tileset of the map, or -1.
+func (m *Map) indexOf(ts *TileSet) int {
+	for i, s := range m.TileSets {
+		if s == ts {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddTileSet adds a tileset after all other tilesets of the map and assigns
+// its first global ID. No tiles need to be remapped.
+func (m *Map) AddTileSet(ts *TileSet) error {
+	if m.indexOf(ts) >= 0 {
+		return errors.Errorf("tileset '%s' is already part of the map", ts.Name)
+	}
+	first := uint32(1)
+	for _, s := range m.TileSets {
+		if s.FirstGID+s.Span() > first {
+			first = s.FirstGID + s.Span()
+		}
+	}
+	ts.FirstGID = first
+	m.TileSets = append(m.TileSets, ts)
+	return nil
+}
+
+// RemoveTileSet removes a tileset from the map. Its tiles are removed from
+// all tile layers and tile objects referencing it lose their tile, the
+// global IDs of the following tilesets are moved down to close the gap.
+func (m *Map) RemoveTileSet(ts *TileSet) error {
+	i := m.indexOf(ts)
+	if i < 0 {
+		return errors.Errorf("tileset '%s' is not part of the map", ts.Name)
+	}
+	sets := append(append([]*TileSet{}, m.TileSets[:i]...), m.TileSets[i+1:]...)
+	return m.setTileSets(sets, func(s *TileSet) *TileSet {
+		if s == ts {
+			return nil
+		}
+		return s
+	})
+}
+
+// ReplaceTileSet replaces a tileset of the map with another one, e.g. an
+// updated version of it. Tiles keep their local IDs, tiles outside of the
+// new tileset are removed. The global IDs of the following tilesets are
+// moved if the new tileset has a different span.
+func (m *Map) ReplaceTileSet(old, ts *TileSet) error {
+	i := m.indexOf(old)
+	if i < 0 {
+		return errors.Errorf("tileset '%s' is not part of the map", old.Name)
+	}
+	if m.indexOf(ts) >= 0 {
+		return errors.Errorf("tileset '%s' is already part of the map", ts.Name)
+	}
+	sets := append([]*TileSet{}, m.TileSets...)
+	sets[i] = ts
+	return m.setTileSets(sets, func(s *TileSet) *TileSet {
+		if s == old {
+			return ts
+		}
+		return s
+	})
+}
@@ -0,0 +1,119 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTileSetMap(t *testing.T) (*Map, *Layer, *Object) {
+	m := &Map{Width: 5, Height: 1, TileWidth: 16, TileHeight: 16}
+	for _, ts := range []*TileSet{
+		{Name: "a", TileCount: 4},
+		{Name: "b", TileCount: 2},
+		{Name: "c", TileCount: 3},
+	} {
+		require.NoError(t, m.AddTileSet(ts))
+	}
+	layer := NewTileLayer("ground")
+	require.NoError(t, m.AddLayer(nil, -1, layer))
+	flipped := TileInstance(FlippedHorizontallyFlag | 5)
+	require.NoError(t, layer.Data.Encode(5, []TileInstance{1, flipped, 7, 9, 6}))
+	group := NewObjectGroup("objects")
+	require.NoError(t, m.AddLayer(nil, -1, group))
+	gid := FlippedVerticallyFlag | 8
+	obj := &Object{GID: &gid}
+	require.NoError(t, m.AddObject(group, obj))
+	return m, layer, obj
+}
+
+func TestAddTileSet(t *testing.T) {
+	m, _, _ := newTileSetMap(t)
+	assert.EqualValues(t, 1, m.TileSets[0].FirstGID)
+	assert.EqualValues(t, 5, m.TileSets[1].FirstGID)
+	assert.EqualValues(t, 7, m.TileSets[2].FirstGID)
+	assert.Equal(t, m.TileSets[1], m.TileSetFor(6))
+	assert.Nil(t, m.TileSetFor(10))
+	assert.Error(t, m.AddTileSet(m.TileSets[0]))
+
+	// image collections reserve IDs up to their largest tile ID
+	collection := &TileSet{Name: "d", TileCount: 2, Tiles: []*Tile{{ID: 0}, {ID: 5}}}
+	assert.EqualValues(t, 6, collection.Span())
+}
+
+func TestRemoveTileSet(t *testing.T) {
+	m, layer, obj := newTileSetMap(t)
+	require.NoError(t, m.RemoveTileSet(m.TileSets[1]))
+	require.Len(t, m.TileSets, 2)
+	assert.EqualValues(t, 5, m.TileSets[1].FirstGID)
+	tiles, err := layer.Data.Tiles()
+	require.NoError(t, err)
+	assert.Equal(t, []TileInstance{1, 0, 5, 7, 0}, tiles)
+	assert.Equal(t, FlippedVerticallyFlag|6, *obj.GID)
+
+	// tile objects of a removed tileset lose their tile
+	require.NoError(t, m.RemoveTileSet(m.TileSets[1]))
+	assert.Nil(t, obj.GID)
+	out, err := xml.Marshal(obj)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "gid")
+}
+
+func TestReplaceTileSet(t *testing.T) {
+	m, layer, obj := newTileSetMap(t)
+	smaller := &TileSet{Name: "a2", TileCount: 1}
+	require.NoError(t, m.ReplaceTileSet(m.TileSets[0], smaller))
+	assert.Equal(t, smaller, m.TileSets[0])
+	assert.EqualValues(t, []uint32{1, 2, 4}, []uint32{m.TileSets[0].FirstGID, m.TileSets[1].FirstGID, m.TileSets[2].FirstGID})
+	tiles, err := layer.Data.Tiles()
+	require.NoError(t, err)
+	assert.Equal(t, []TileInstance{1, TileInstance(FlippedHorizontallyFlag | 2), 4, 6, 3}, tiles)
+	assert.Equal(t, FlippedVerticallyFlag|5, *obj.GID)
+}
+
+func TestReplaceTileSetChunks(t *testing.T) {
+	fp, err := os.Open("resources/modern.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	// tiles keep their local IDs when a tileset grows
+	require.NoError(t, m.AddTileSet(&TileSet{Name: "extra", TileCount: 10}))
+	extra := m.TileSets[len(m.TileSets)-1]
+	assert.EqualValues(t, 26, extra.FirstGID)
+	bigger := *m.TileSets[0]
+	bigger.TileCount = 30
+	require.NoError(t, m.ReplaceTileSet(m.TileSets[0], &bigger))
+	assert.EqualValues(t, 31, extra.FirstGID)
+
+	tiles := make(map[[2]int]uint32)
+	err = m.Layers[0].Data.ForEach(0, func(x, y int, tile TileInstance) {
+		if tile.GID() != 0 {
+			tiles[[2]int{x, y}] = tile.GID()
+		}
+	})
+	require.NoError(t, err)
+	assert.Len(t, tiles, 18)
+	assert.EqualValues(t, 25, tiles[[2]int{-1, 15}])
+	assert.EqualValues(t, 7, tiles[[2]int{15, 15}])
+
+	xmlTiles, err := m.Layers[3].Data.Tiles()
+	require.NoError(t, err)
+	assert.Equal(t, []TileInstance{1, 0, TileInstance(FlippedHorizontallyFlag | 2)}, xmlTiles)
+
+	// removing the tileset clears the chunks
+	require.NoError(t, m.RemoveTileSet(&bigger))
+	assert.EqualValues(t, 1, extra.FirstGID)
+	tiles = make(map[[2]int]uint32)
+	err = m.Layers[0].Data.ForEach(0, func(x, y int, tile TileInstance) {
+		if tile.GID() != 0 {
+			tiles[[2]int{x, y}] = tile.GID()
+		}
+	})
+	require.NoError(t, err)
+	assert.Empty(t, tiles)
+}
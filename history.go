@@ -0,0 +1,420 @@
+package tmx
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Command is an invertible edit of a map. Apply must leave the map unchanged
+// if it fails, Undo restores the map to its state before Apply.
+type Command interface {
+	Apply(m *Map) error
+	Undo(m *Map) error
+}
+
+// SetTilesCommand changes the tiles of a rectangle of a tile layer, which is
+// Width tiles wide and starts at position (X, Y). The tiles are given row by
+// row.
+type SetTilesCommand struct {
+	Layer *Layer
+	X, Y  int
+	Width int
+	Tiles []TileInstance
+
+	old []TileInstance
+}
+
+func (c *SetTilesCommand) layerWidth(m *Map) int {
+	if c.Layer.Width != nil {
+		return int(*c.Layer.Width)
+	}
+	return int(m.Width)
+}
+
+func (c *SetTilesCommand) Apply(m *Map) error {
+	if c.Layer.Data == nil {
+		return errors.Errorf("layer '%s' is not a tile layer", c.Layer.Name)
+	}
+	if c.Width <= 0 {
+		return errors.New("invalid region width")
+	}
+	height := (len(c.Tiles) + c.Width - 1) / c.Width
+	old, err := c.Layer.Data.Region(c.layerWidth(m), c.X, c.Y, c.Width, height)
+	if err != nil {
+		return errors.Wrap(err, "unable to set tiles")
+	}
+	c.old = old[:len(c.Tiles)]
+	return errors.Wrap(c.Layer.Data.SetRegion(c.layerWidth(m), c.X, c.Y, c.Width, c.Tiles), "unable to set tiles")
+}
+
+func (c *SetTilesCommand) Undo(m *Map) error {
+	return errors.Wrap(c.Layer.Data.SetRegion(c.layerWidth(m), c.X, c.Y, c.Width, c.old), "unable to restore tiles")
+}
+
+// AddObjectCommand adds an object to an object group like Map.AddObject.
+// The object gets a new ID the first time it is applied, which it keeps when
+// it is redone.
+type AddObjectCommand struct {
+	Group  *Layer
+	Object *Object
+
+	added bool
+}
+
+func (c *AddObjectCommand) Apply(m *Map) error {
+	if !c.added {
+		err := m.AddObject(c.Group, c.Object)
+		c.added = err == nil
+		return err
+	}
+	if _, _, exists := m.parentOf(c.Group); !exists {
+		return errors.Errorf("object group '%s' is not part of the map", c.Group.Name)
+	}
+	c.Group.Objects = append(c.Group.Objects, c.Object)
+	return nil
+}
+
+func (c *AddObjectCommand) Undo(m *Map) error {
+	group, index := m.groupOf(c.Object)
+	if group == nil {
+		return errors.Errorf("object %d is not part of the map", c.Object.ID)
+	}
+	group.Objects = append(group.Objects[:index], group.Objects[index+1:]...)
+	return nil
+}
+
+// RemoveObjectCommand removes an object from its object group.
+type RemoveObjectCommand struct {
+	Object *Object
+
+	group *Layer
+	index int
+}
+
+func (c *RemoveObjectCommand) Apply(m *Map) error {
+	group, index := m.groupOf(c.Object)
+	if group == nil {
+		return errors.Errorf("object %d is not part of the map", c.Object.ID)
+	}
+	c.group, c.index = group, index
+	group.Objects = append(group.Objects[:index], group.Objects[index+1:]...)
+	return nil
+}
+
+func (c *RemoveObjectCommand) Undo(m *Map) error {
+	if _, _, exists := m.parentOf(c.group); !exists {
+		return errors.Errorf("object group '%s' is not part of the map", c.group.Name)
+	}
+	// the group may have been changed outside of the history
+	if c.index > len(c.group.Objects) {
+		c.index = len(c.group.Objects)
+	}
+	objs := append(c.group.Objects, nil)
+	copy(objs[c.index+1:], objs[c.index:])
+	objs[c.index] = c.Object
+	c.group.Objects = objs
+	return nil
+}
+
+// MoveObjectCommand moves an object to (X, Y) in pixels.
+type MoveObjectCommand struct {
+	Object *Object
+	X, Y   float64
+
+	oldX, oldY float64
+}
+
+func (c *MoveObjectCommand) Apply(m *Map) error {
+	c.oldX, c.oldY = c.Object.X, c.Object.Y
+	c.Object.X, c.Object.Y = c.X, c.Y
+	return nil
+}
+
+func (c *MoveObjectCommand) Undo(m *Map) error {
+	c.Object.X, c.Object.Y = c.oldX, c.oldY
+	return nil
+}
+
+// SetPropertyCommand sets a custom property of a map, layer, object or
+// tileset. Properties points to the Properties field of its owner, e.g.
+// &layer.Properties. A nil Property removes the property called Name.
+type SetPropertyCommand struct {
+	Properties **Properties
+	Name       string
+	Property   *Property
+
+	old *Properties
+}
+
+func (c *SetPropertyCommand) Apply(m *Map) error {
+	c.old = *c.Properties
+	props := &Properties{}
+	if c.old != nil {
+		for _, p := range c.old.Properties {
+			if p.Name != c.Name {
+				props.Properties = append(props.Properties, p)
+			}
+		}
+	}
+	if c.Property != nil {
+		p := *c.Property
+		p.Name = c.Name
+		props.Properties = append(props.Properties, p)
+	}
+	if len(props.Properties) == 0 {
+		props = nil
+	}
+	*c.Properties = props
+	return nil
+}
+
+func (c *SetPropertyCommand) Undo(m *Map) error {
+	*c.Properties = c.old
+	return nil
+}
+
+// AddLayerCommand adds a layer like Map.AddLayer. IDs are only assigned the
+// first time it is applied.
+type AddLayerCommand struct {
+	Parent *Layer
+	Index  int
+	Layer  *Layer
+
+	added bool
+}
+
+func (c *AddLayerCommand) Apply(m *Map) error {
+	if !c.added {
+		err := m.AddLayer(c.Parent, c.Index, c.Layer)
+		c.added = err == nil
+		return err
+	}
+	err := m.checkParent(c.Parent)
+	if err != nil {
+		return err
+	}
+	layers := m.children(c.Parent)
+	*layers = insert(*layers, c.Index, c.Layer)
+	return nil
+}
+
+func (c *AddLayerCommand) Undo(m *Map) error {
+	return m.RemoveLayer(c.Layer)
+}
+
+// RemoveLayerCommand removes a layer like Map.RemoveLayer.
+type RemoveLayerCommand struct {
+	Layer *Layer
+
+	parent *Layer
+	index  int
+}
+
+func (c *RemoveLayerCommand) Apply(m *Map) error {
+	parent, index, exists := m.parentOf(c.Layer)
+	if !exists {
+		return errors.Errorf("layer '%s' is not part of the map", c.Layer.Name)
+	}
+	c.parent, c.index = parent, index
+	return m.RemoveLayer(c.Layer)
+}
+
+func (c *RemoveLayerCommand) Undo(m *Map) error {
+	err := m.checkParent(c.parent)
+	if err != nil {
+		return err
+	}
+	layers := m.children(c.parent)
+	*layers = insert(*layers, c.index, c.Layer)
+	return nil
+}
+
+// checkParent returns an error if a group is no longer part of the map, nil
+// stands for the top-level layers.
+func (m *Map) checkParent(parent *Layer) error {
+	if parent == nil {
+		return nil
+	}
+	if _, _, exists := m.parentOf(parent); !exists {
+		return errors.Errorf("group '%s' is not part of the map", parent.Name)
+	}
+	return nil
+}
+
+// MoveLayerCommand moves a layer like Map.MoveLayer.
+type MoveLayerCommand struct {
+	Layer  *Layer
+	Parent *Layer
+	Index  int
+
+	oldParent *Layer
+	oldIndex  int
+}
+
+func (c *MoveLayerCommand) Apply(m *Map) error {
+	parent, index, exists := m.parentOf(c.Layer)
+	if !exists {
+		return errors.Errorf("layer '%s' is not part of the map", c.Layer.Name)
+	}
+	err := m.MoveLayer(c.Layer, c.Parent, c.Index)
+	if err != nil {
+		return err
+	}
+	c.oldParent, c.oldIndex = parent, index
+	return nil
+}
+
+func (c *MoveLayerCommand) Undo(m *Map) error {
+	return m.MoveLayer(c.Layer, c.oldParent, c.oldIndex)
+}
+
+// History applies commands to a map and keeps them to undo and redo them.
+// Commands applied between Begin and Commit are undone and redone together.
+type History struct {
+	Limit int // The maximum number of steps that can be undone, 0 means no limit.
+
+	m     *Map
+	undo  [][]Command
+	redo  [][]Command
+	tx    []Command
+	depth int
+}
+
+// NewHistory creates an empty history for edits of m.
+func NewHistory(m *Map) *History {
+	return &History{m: m}
+}
+
+// Do applies a command. Outside of a transaction it becomes a step of its
+// own, and the steps that could be redone are discarded.
+func (h *History) Do(c Command) error {
+	err := c.Apply(h.m)
+	if err != nil {
+		return err
+	}
+	if h.depth > 0 {
+		h.tx = append(h.tx, c)
+		return nil
+	}
+	h.push([]Command{c})
+	return nil
+}
+
+func (h *History) push(step []Command) {
+	h.undo = append(h.undo, step)
+	h.redo = nil
+	if h.Limit > 0 && len(h.undo) > h.Limit {
+		h.undo = h.undo[len(h.undo)-h.Limit:]
+	}
+}
+
+// Begin starts a transaction. Transactions can be nested, the commands are
+// grouped into a single step by the outermost Commit.
+func (h *History) Begin() {
+	h.depth++
+}
+
+// Commit ends a transaction.
+func (h *History) Commit() error {
+	if h.depth == 0 {
+		return errors.New("no transaction in progress")
+	}
+	h.depth--
+	if h.depth == 0 && len(h.tx) > 0 {
+		h.push(h.tx)
+		h.tx = nil
+	}
+	return nil
+}
+
+// Rollback undoes the commands of the current transaction, including those
+// of all enclosing transactions, and ends them.
+func (h *History) Rollback() error {
+	if h.depth == 0 {
+		return errors.New("no transaction in progress")
+	}
+	tx := h.tx
+	h.tx, h.depth = nil, 0
+	return undoAll(h.m, tx)
+}
+
+// undoAll undoes the commands of a step in reverse order. If a command
+// fails, the commands undone before it are applied again, so that the step
+// stays applied.
+func undoAll(m *Map, step []Command) error {
+	for i := len(step) - 1; i >= 0; i-- {
+		err := step[i].Undo(m)
+		if err != nil {
+			for _, c := range step[i+1:] {
+				if applyErr := c.Apply(m); applyErr != nil {
+					return errors.Wrapf(err, "unable to undo, and unable to apply the step again (%v)", applyErr)
+				}
+			}
+			return errors.Wrap(err, "unable to undo")
+		}
+	}
+	return nil
+}
+
+// applyAll applies the commands of a step in order. If a command fails, the
+// commands applied before it are undone in reverse order, so that the step
+// stays undone.
+func applyAll(m *Map, step []Command) error {
+	for i, c := range step {
+		err := c.Apply(m)
+		if err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if undoErr := step[j].Undo(m); undoErr != nil {
+					return errors.Wrapf(err, "unable to redo, and unable to undo the step again (%v)", undoErr)
+				}
+			}
+			return errors.Wrap(err, "unable to redo")
+		}
+	}
+	return nil
+}
+
+// CanUndo returns true if there is a step that can be undone.
+func (h *History) CanUndo() bool {
+	return len(h.undo) > 0
+}
+
+// CanRedo returns true if there is a step that can be redone.
+func (h *History) CanRedo() bool {
+	return len(h.redo) > 0
+}
+
+// Undo undoes the last step.
+func (h *History) Undo() error {
+	if h.depth > 0 {
+		return errors.New("unable to undo during a transaction")
+	}
+	if len(h.undo) == 0 {
+		return errors.New("nothing to undo")
+	}
+	step := h.undo[len(h.undo)-1]
+	err := undoAll(h.m, step)
+	if err != nil {
+		return err
+	}
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, step)
+	return nil
+}
+
+// Redo applies the last undone step again.
+func (h *History) Redo() error {
+	if h.depth > 0 {
+		return errors.New("unable to redo during a transaction")
+	}
+	if len(h.redo) == 0 {
+		return errors.New("nothing to redo")
+	}
+	step := h.redo[len(h.redo)-1]
+	err := applyAll(h.m, step)
+	if err != nil {
+		return err
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, step)
+	return nil
+}
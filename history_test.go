@@ -0,0 +1,175 @@
+package tmx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newHistoryMap(t *testing.T) (*Map, *Layer, *Layer) {
+	m := &Map{Width: 3, Height: 2, TileWidth: 16, TileHeight: 16}
+	ground := NewTileLayer("ground")
+	require.NoError(t, m.AddLayer(nil, -1, ground))
+	objects := NewObjectGroup("objects")
+	require.NoError(t, m.AddLayer(nil, -1, objects))
+	return m, ground, objects
+}
+
+func TestHistoryTiles(t *testing.T) {
+	m, ground, _ := newHistoryMap(t)
+	h := NewHistory(m)
+	require.NoError(t, h.Do(&SetTilesCommand{Layer: ground, X: 1, Y: 0, Width: 2, Tiles: []TileInstance{1, 2, 3, 4}}))
+	require.NoError(t, h.Do(&SetTilesCommand{Layer: ground, X: 0, Y: 1, Width: 1, Tiles: []TileInstance{9}}))
+	tiles, _ := ground.Data.Tiles()
+	assert.Equal(t, []TileInstance{0, 1, 2, 9, 3, 4}, tiles)
+
+	require.NoError(t, h.Undo())
+	tiles, _ = ground.Data.Tiles()
+	assert.Equal(t, []TileInstance{0, 1, 2, 0, 3, 4}, tiles)
+	require.NoError(t, h.Undo())
+	tiles, _ = ground.Data.Tiles()
+	assert.Equal(t, []TileInstance{0, 0, 0, 0, 0, 0}, tiles)
+	assert.False(t, h.CanUndo())
+	assert.Error(t, h.Undo())
+
+	require.NoError(t, h.Redo())
+	tiles, _ = ground.Data.Tiles()
+	assert.Equal(t, []TileInstance{0, 1, 2, 0, 3, 4}, tiles)
+	assert.True(t, h.CanRedo())
+
+	// a new step discards the steps that could be redone
+	require.NoError(t, h.Do(&SetTilesCommand{Layer: ground, X: 0, Y: 0, Width: 1, Tiles: []TileInstance{5}}))
+	assert.False(t, h.CanRedo())
+
+	// failed commands are not recorded
+	assert.Error(t, h.Do(&SetTilesCommand{Layer: ground, X: 3, Y: 0, Width: 1, Tiles: []TileInstance{5}}))
+	require.NoError(t, h.Undo())
+	require.NoError(t, h.Undo())
+	assert.False(t, h.CanUndo())
+}
+
+func TestHistoryObjects(t *testing.T) {
+	m, ground, objects := newHistoryMap(t)
+	h := NewHistory(m)
+	door := &Object{Name: "door"}
+	require.NoError(t, h.Do(&AddObjectCommand{Group: objects, Object: door}))
+	assert.EqualValues(t, 1, door.ID)
+	require.NoError(t, h.Do(&MoveObjectCommand{Object: door, X: 10, Y: 20}))
+	require.NoError(t, h.Do(&SetPropertyCommand{Properties: &door.Properties, Name: "locked", Property: &Property{Value: "true"}}))
+	require.NoError(t, h.Do(&RemoveObjectCommand{Object: door}))
+	assert.Empty(t, objects.Objects)
+
+	require.NoError(t, h.Undo())
+	assert.Equal(t, []*Object{door}, objects.Objects)
+	assert.Equal(t, "true", door.Properties.Properties[0].Value)
+	require.NoError(t, h.Undo())
+	assert.Nil(t, door.Properties)
+	require.NoError(t, h.Undo())
+	assert.Equal(t, 0.0, door.X)
+	require.NoError(t, h.Undo())
+	assert.Empty(t, objects.Objects)
+
+	// redoing keeps the ID
+	require.NoError(t, h.Redo())
+	assert.EqualValues(t, 1, door.ID)
+	assert.EqualValues(t, 2, m.NextObjectId)
+
+	require.NoError(t, h.Do(&MoveLayerCommand{Layer: objects, Index: 0}))
+	assert.Equal(t, []*Layer{objects, ground}, m.Layers)
+	require.NoError(t, h.Undo())
+	assert.Equal(t, []*Layer{ground, objects}, m.Layers)
+
+	require.NoError(t, h.Do(&RemoveLayerCommand{Layer: ground}))
+	require.NoError(t, h.Undo())
+	assert.Equal(t, []*Layer{ground, objects}, m.Layers)
+
+	// copies of existing objects get a new ID and undo removes the copy
+	cp := &Object{ID: door.ID, Name: "copy"}
+	require.NoError(t, h.Do(&AddObjectCommand{Group: objects, Object: cp}))
+	assert.EqualValues(t, 2, cp.ID)
+	require.NoError(t, h.Undo())
+	assert.Equal(t, []*Object{door}, objects.Objects)
+	assert.Error(t, h.Do(&AddObjectCommand{Group: NewObjectGroup("other"), Object: &Object{}}))
+	assert.Error(t, h.Do(&RemoveObjectCommand{Object: cp}))
+}
+
+func TestHistoryTransactions(t *testing.T) {
+	m, ground, objects := newHistoryMap(t)
+	h := NewHistory(m)
+	h.Begin()
+	require.NoError(t, h.Do(&SetTilesCommand{Layer: ground, Width: 1, Tiles: []TileInstance{1}}))
+	h.Begin()
+	require.NoError(t, h.Do(&AddObjectCommand{Group: objects, Object: &Object{}}))
+	require.NoError(t, h.Commit())
+	assert.False(t, h.CanUndo())
+	assert.Error(t, h.Undo())
+	require.NoError(t, h.Commit())
+	assert.Error(t, h.Commit())
+
+	require.NoError(t, h.Undo())
+	tiles, _ := ground.Data.Tiles()
+	assert.EqualValues(t, 0, tiles[0])
+	assert.Empty(t, objects.Objects)
+	assert.False(t, h.CanUndo())
+
+	h.Begin()
+	require.NoError(t, h.Do(&SetTilesCommand{Layer: ground, Width: 1, Tiles: []TileInstance{1}}))
+	require.NoError(t, h.Rollback())
+	tiles, _ = ground.Data.Tiles()
+	assert.EqualValues(t, 0, tiles[0])
+	assert.False(t, h.CanUndo())
+
+	// the oldest steps are dropped once the limit is reached
+	h.Limit = 2
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, h.Do(&SetTilesCommand{Layer: ground, Width: 1, Tiles: []TileInstance{TileInstance(i)}}))
+	}
+	require.NoError(t, h.Undo())
+	require.NoError(t, h.Undo())
+	assert.False(t, h.CanUndo())
+	tiles, _ = ground.Data.Tiles()
+	assert.EqualValues(t, 1, tiles[0])
+}
+
+func TestHistoryFailures(t *testing.T) {
+	m, _, objects := newHistoryMap(t)
+	h := NewHistory(m)
+	a, b := &Object{X: 1}, &Object{X: 2}
+	require.NoError(t, h.Do(&AddObjectCommand{Group: objects, Object: a}))
+	require.NoError(t, h.Do(&AddObjectCommand{Group: objects, Object: b}))
+
+	// a redo failing partway leaves the step undone
+	h.Begin()
+	require.NoError(t, h.Do(&MoveObjectCommand{Object: a, X: 10}))
+	require.NoError(t, h.Do(&RemoveObjectCommand{Object: b}))
+	require.NoError(t, h.Commit())
+	require.NoError(t, h.Undo())
+	require.NoError(t, m.RemoveObject(b.ID))
+	assert.Error(t, h.Redo())
+	assert.Equal(t, 1.0, a.X)
+	assert.True(t, h.CanRedo())
+
+	// objects removed outside of the history do not break undoing
+	require.NoError(t, h.Do(&RemoveObjectCommand{Object: a}))
+	assert.Empty(t, objects.Objects)
+	require.NoError(t, m.AddObject(objects, b))
+	require.NoError(t, m.RemoveObject(b.ID))
+	require.NoError(t, h.Undo())
+	assert.Equal(t, []*Object{a}, objects.Objects)
+
+	// an undo failing partway leaves the step applied
+	group, err := m.AddGroup(nil, -1, "group")
+	require.NoError(t, err)
+	inner := NewObjectGroup("inner")
+	h.Begin()
+	require.NoError(t, h.Do(&AddLayerCommand{Parent: group, Index: -1, Layer: inner}))
+	require.NoError(t, h.Do(&RemoveLayerCommand{Layer: inner}))
+	require.NoError(t, h.Do(&MoveObjectCommand{Object: a, X: 20}))
+	require.NoError(t, h.Commit())
+	require.NoError(t, m.RemoveLayer(group))
+	assert.Error(t, h.Undo())
+	assert.Equal(t, 20.0, a.X)
+	assert.True(t, h.CanUndo())
+	assert.Empty(t, group.Layers)
+}
@@ -0,0 +1,235 @@
+package tmx
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DecodeOptions configures DecodeImages.
+type DecodeOptions struct {
+	Workers  int                   // The maximum number of images decoded in parallel. Defaults to runtime.NumCPU().
+	Progress func(done, total int) // Called on the calling goroutine after each image has been decoded (optional).
+}
+
+// Embedded returns true if the image data is stored inside the TMX or TSX
+// file rather than in an external image file.
+func (img *Image) Embedded() bool {
+	return img.Source == "" && img.Data != nil
+}
+
+// Key returns the identifier under which the decoded image is stored by
+// DecodeImages. For external images this is the source path resolved against
+// dir. Embedded images get a synthetic key derived from their contents.
+func (img *Image) Key(dir string) string {
+	if img.Embedded() {
+		return fmt.Sprintf("embedded:%s:%x", img.Format, sha1.Sum(img.Data.Data))
+	}
+	return resolvePath(dir, img.Source)
+}
+
+// Decode opens and decodes the image, relative sources are resolved against
+// dir. Embedded images are decoded from their base64 data. The decoders for
+// the required image formats must be registered by the caller (e.g. by
+// importing image/png).
+func (img *Image) Decode(dir string) (image.Image, error) {
+	var r io.Reader
+	if img.Embedded() {
+		if img.Data.Encoding == nil || *img.Data.Encoding != "base64" {
+			return nil, errors.New("embedded image data must be base64 encoded")
+		}
+		var err error
+		r, err = img.Data.base64Reader()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read embedded image")
+		}
+	} else {
+		f, err := os.Open(img.Key(dir))
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open image")
+		}
+		defer f.Close()
+		r = f
+	}
+	decoded, _, err := image.Decode(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decode image '%s'", img.Key(dir))
+	}
+	return img.applyTrans(decoded)
+}
+
+// TransColor parses the trans attribute of the image, both the “#FF00FF”
+// form and the legacy form without the leading '#' are accepted. If no
+// transparent color is set, ok will be false.
+func (img *Image) TransColor() (c color.NRGBA, ok bool, err error) {
+	if img.Trans == nil || *img.Trans == "" {
+		return c, false, nil
+	}
+	if len(strings.TrimPrefix(strings.TrimSpace(*img.Trans), "#")) != 6 {
+		return c, false, errors.Errorf("invalid transparent color: %s", *img.Trans)
+	}
+	parsed, err := ParseColor(*img.Trans)
+	if err != nil {
+		return c, false, errors.Wrap(err, "invalid transparent color")
+	}
+	return color.NRGBA(parsed), true, nil
+}
+
+// applyTrans replaces every pixel matching the transparent color of the
+// image with a fully transparent one. The source is returned unchanged if no
+// transparent color is set.
+func (img *Image) applyTrans(src image.Image) (image.Image, error) {
+	key, ok, err := img.TransColor()
+	if err != nil || !ok {
+		return src, err
+	}
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			if c.R == key.R && c.G == key.G && c.B == key.B {
+				c = color.NRGBA{}
+			}
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	return dst, nil
+}
+
+// Images returns every image referenced by the map: the tileset images, the
+// tile images of image collection tilesets and the images of all (nested)
+// image layers.
+func (m *Map) Images() []*Image {
+	var imgs []*Image
+	for _, set := range m.TileSets {
+		if set.Image != nil {
+			imgs = append(imgs, set.Image)
+		}
+		for _, tile := range set.Tiles {
+			if tile.Image != nil {
+				imgs = append(imgs, tile.Image)
+			}
+		}
+	}
+	var walk func(layers []*Layer)
+	walk = func(layers []*Layer) {
+		for _, l := range layers {
+			if l.Image != nil {
+				imgs = append(imgs, l.Image)
+			}
+			walk(l.Layers)
+		}
+	}
+	walk(m.Layers)
+	return imgs
+}
+
+type decodeResult struct {
+	index int
+	img   image.Image
+	err   error
+}
+
+// DecodeImages decodes the images in parallel and returns them keyed by
+// Image.Key(dir). Images sharing a key are only decoded once. Decoding
+// continues past failures and all of them are returned together as an
+// ErrorList. When the context is cancelled no further images are decoded and
+// the context error is returned.
+func DecodeImages(ctx context.Context, dir string, imgs []*Image, opts *DecodeOptions) (map[string]image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "image decoding cancelled")
+	}
+	if opts == nil {
+		opts = &DecodeOptions{}
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var keys []string
+	var jobs []*Image
+	seen := make(map[string]bool)
+	for _, img := range imgs {
+		key := img.Key(dir)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+		jobs = append(jobs, img)
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	indices := make(chan int)
+	results := make(chan decodeResult)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range indices {
+				img, err := jobs[i].Decode(dir)
+				select {
+				case results <- decodeResult{index: i, img: img, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(indices)
+		for i := range jobs {
+			select {
+			case indices <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	decoded := make(map[string]image.Image, len(jobs))
+	errs := make([]error, len(jobs))
+	for done := 1; done <= len(jobs); done++ {
+		select {
+		case res := <-results:
+			if res.err != nil {
+				errs[res.index] = res.err
+			} else {
+				decoded[keys[res.index]] = res.img
+			}
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "image decoding cancelled")
+		}
+		if opts.Progress != nil {
+			opts.Progress(done, len(jobs))
+		}
+	}
+
+	var el ErrorList
+	for _, err := range errs {
+		if err != nil {
+			el = append(el, err)
+		}
+	}
+	return decoded, el.Err()
+}
+
+func resolvePath(dir, source string) string {
+	if filepath.IsAbs(source) {
+		return filepath.Clean(source)
+	}
+	return filepath.Join(dir, source)
+}
@@ -0,0 +1,138 @@
+package tmx
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	_ "image/png"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeImages(t *testing.T) {
+	fp, err := os.Open("resources/cave.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	var progress []int
+	imgs := append(m.Images(), m.Images()...)
+	decoded, err := DecodeImages(context.Background(), "resources", imgs, &DecodeOptions{
+		Workers:  4,
+		Progress: func(done, total int) { progress = append(progress, done, total) },
+	})
+	require.NoError(t, err)
+	require.Len(t, decoded, 1)
+	assert.Equal(t, []int{1, 1}, progress)
+	img := decoded[m.TileSets[0].Image.Key("resources")]
+	require.NotNil(t, img)
+	assert.Equal(t, 86, img.Bounds().Dx())
+}
+
+func TestDecodeImagesErrors(t *testing.T) {
+	imgs := []*Image{
+		{Source: "missing1.png"},
+		{Source: "cave.png"},
+		{Source: "missing2.png"},
+	}
+	decoded, err := DecodeImages(context.Background(), "resources", imgs, nil)
+	require.Error(t, err)
+	el, ok := err.(ErrorList)
+	require.True(t, ok)
+	assert.Len(t, el, 2)
+	assert.Len(t, decoded, 1)
+}
+
+func TestDecodeImagesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := DecodeImages(ctx, "resources", []*Image{{Source: "cave.png"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestImageCollection(t *testing.T) {
+	fp, err := os.Open("resources/collection.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	imgs := m.Images()
+	require.Len(t, imgs, 2)
+	decoded, err := DecodeImages(context.Background(), "resources", imgs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 86, decoded[imgs[0].Key("resources")].Bounds().Dx())
+	assert.Equal(t, 265, decoded[imgs[1].Key("resources")].Bounds().Dx())
+}
+
+func TestTransColor(t *testing.T) {
+	for _, trans := range []string{"#FF00FF", "ff00ff"} {
+		img := &Image{Trans: &trans}
+		c, ok, err := img.TransColor()
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, color.NRGBA{R: 0xFF, G: 0x00, B: 0xFF, A: 0xFF}, c)
+	}
+
+	_, ok, err := (&Image{}).TransColor()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	invalid := "#F0F"
+	_, _, err = (&Image{Trans: &invalid}).TransColor()
+	assert.Error(t, err)
+}
+
+func TestDecodeTrans(t *testing.T) {
+	plain, err := (&Image{Source: "cave.png"}).Decode("resources")
+	require.NoError(t, err)
+	r, g, b, _ := plain.At(0, 0).RGBA()
+	trans := fmt.Sprintf("%02x%02x%02x", r>>8, g>>8, b>>8)
+	keyed, err := (&Image{Source: "cave.png", Trans: &trans}).Decode("resources")
+	require.NoError(t, err)
+
+	bounds := plain.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pr, pg, pb, _ := plain.At(x, y).RGBA()
+			_, _, _, a := keyed.At(x, y).RGBA()
+			if pr == r && pg == g && pb == b {
+				assert.Zero(t, a, "pixel (%d, %d) not transparent", x, y)
+			} else {
+				assert.NotZero(t, a, "pixel (%d, %d) transparent", x, y)
+			}
+		}
+	}
+}
+
+func TestDecodeEmbedded(t *testing.T) {
+	fp, err := os.Open("resources/embedded.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	imgs := m.Images()
+	require.Len(t, imgs, 2)
+	for _, img := range imgs {
+		assert.True(t, img.Embedded())
+		assert.NotContains(t, img.Key("resources"), "resources")
+	}
+	assert.NotEqual(t, imgs[0].Key("resources"), imgs[1].Key("resources"))
+
+	decoded, err := DecodeImages(context.Background(), "resources", imgs, nil)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+	for _, img := range imgs {
+		d := decoded[img.Key("resources")]
+		require.NotNil(t, d)
+		assert.Equal(t, 32, d.Bounds().Dx())
+		assert.Equal(t, 16, d.Bounds().Dy())
+		r, _, b, _ := d.At(20, 8).RGBA()
+		assert.True(t, b > r, "expected blue pixel in second tile")
+	}
+}
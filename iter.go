@@ -60,7 +60,7 @@ type xmlIterator struct {
 
 func (xi *xmlIterator) Next() bool {
 	xi.i++
-	return int(xi.i) >= len(xi.d.TileData)
+	return int(xi.i) <= len(xi.d.TileData)
 }
 
 func (xi *xmlIterator) Error() error {
@@ -68,33 +68,63 @@ func (xi *xmlIterator) Error() error {
 }
 
 func (xi *xmlIterator) Get() TileInstance {
-	return TileInstance(xi.d.TileData[xi.i].GID)
+	return TileInstance(xi.d.TileData[xi.i-1].GID)
 }
 
 func (xi *xmlIterator) GetIndex() uint32 {
 	return xi.i - 1
 }
 
-type csvIterator struct {
-	d     *Data
-	start int
-	end   int
+// sliceIterator iterates over decoded tiles.
+type sliceIterator struct {
+	tiles []TileInstance
 	i     uint32
-	err   error
-	done  bool
+}
+
+func (si *sliceIterator) Next() bool {
+	si.i++
+	return int(si.i) <= len(si.tiles)
+}
+
+func (si *sliceIterator) Error() error {
+	return nil
+}
+
+func (si *sliceIterator) Get() TileInstance {
+	return si.tiles[si.i-1]
+}
+
+func (si *sliceIterator) GetIndex() uint32 {
+	return si.i - 1
+}
+
+type csvIterator struct {
+	fields [][]byte
+	tok    TileInstance
+	i      uint32
+	err    error
+}
+
+func newCSVIterator(d *Data) *csvIterator {
+	ci := &csvIterator{}
+	data := bytes.TrimSpace(d.Data)
+	if len(data) > 0 {
+		ci.fields = bytes.Split(data, []byte{','})
+	}
+	return ci
 }
 
 func (ci *csvIterator) Next() bool {
-	if ci.done {
+	if ci.err != nil || int(ci.i) >= len(ci.fields) {
 		return false
 	}
-	ci.i++
-	ci.start = ci.end
-	i := bytes.IndexByte(ci.d.Data[ci.start:], ',')
-	if i == -1 {
-		ci.done = true
+	g, err := strconv.ParseUint(string(bytes.TrimSpace(ci.fields[ci.i])), 10, 32)
+	if err != nil {
+		ci.err = err
+		return false
 	}
-	ci.end += i
+	ci.i++
+	ci.tok = TileInstance(g)
 	return true
 }
 
@@ -103,13 +133,7 @@ func (ci *csvIterator) Error() error {
 }
 
 func (ci *csvIterator) Get() TileInstance {
-	g, err := strconv.ParseUint(string(ci.d.Data[ci.start:ci.end]), 10, 32)
-	if err != nil {
-		ci.err = err
-		return 0
-	}
-	gid := uint32(g)
-	return TileInstance(gid)
+	return ci.tok
 }
 
 func (ci *csvIterator) GetIndex() uint32 {
@@ -149,29 +173,20 @@ func (bi *b64Iterator) GetIndex() uint32 {
 	return bi.i - 1
 }
 
+// Iter returns an iterator over the tiles of fixed-size map data, including
+// the changes that have not been flushed yet.
 func (d *Data) Iter() (TileIterator, error) {
 	switch {
+	case d.tiles != nil:
+		return &sliceIterator{tiles: d.tiles}, nil
 	case d.Encoding == nil && d.Compression != nil:
 		return nil, errors.New("compression without encoding is not possible")
 	case d.Encoding == nil && d.Compression == nil:
 		return &xmlIterator{d: d}, nil
 	case *d.Encoding == "csv":
-		return &csvIterator{}, nil
+		return newCSVIterator(d), nil
 	case *d.Encoding == "base64":
-		var r io.Reader
-		var err error
-		r = bytes.NewReader(bytes.TrimSpace(d.Data))
-		r = base64.NewDecoder(base64.StdEncoding, r)
-		switch {
-		case d.Compression == nil, *d.Compression == "":
-			// Do nothing
-		case *d.Compression == "gzip":
-			r, err = gzip.NewReader(r)
-		case *d.Compression == "zlib":
-			r, err = zlib.NewReader(r)
-		default:
-			err = errors.New("invalid encoding")
-		}
+		r, err := d.base64Reader()
 		if err != nil {
 			return nil, errors.Wrap(err, "could not load base64 tile data")
 		}
@@ -182,6 +197,101 @@ func (d *Data) Iter() (TileIterator, error) {
 	}
 }
 
+// base64Reader returns a reader for the decoded (and decompressed) contents
+// of base64 encoded data.
+func (d *Data) base64Reader() (io.Reader, error) {
+	var r io.Reader
+	var err error
+	r = bytes.NewReader(bytes.TrimSpace(d.Data))
+	r = base64.NewDecoder(base64.StdEncoding, r)
+	switch {
+	case d.Compression == nil, *d.Compression == "":
+		// Do nothing
+	case *d.Compression == "gzip":
+		r, err = gzip.NewReader(r)
+	case *d.Compression == "zlib":
+		r, err = zlib.NewReader(r)
+	default:
+		err = errors.Errorf("invalid compression: %s", *d.Compression)
+	}
+	return r, err
+}
+
+// ChunkIter returns an iterator over the tiles of a chunk of an infinite
+// map. The chunk data uses the encoding and compression of its parent data.
+func (d *Data) ChunkIter(c *Chunk) (TileIterator, error) {
+	cd := &Data{
+		Encoding:    d.Encoding,
+		Compression: d.Compression,
+		TileData:    c.TileData,
+		Data:        c.Data,
+	}
+	return cd.Iter()
+}
+
+// ForEach calls fn with the position in tiles of every tile of the layer
+// data, including the tiles of all chunks of an infinite map. The width of
+// the layer is required to position the tiles of fixed-size maps.
+func (d *Data) ForEach(width int, fn func(x, y int, tile TileInstance)) error {
+	if len(d.Chunks) == 0 {
+		if width <= 0 {
+			return errors.Errorf("invalid layer width %d", width)
+		}
+		iter, err := d.Iter()
+		if err != nil {
+			return errors.Wrap(err, "unable to load layer iterator")
+		}
+		for iter.Next() {
+			i := int(iter.GetIndex())
+			fn(i%width, i/width, iter.Get())
+		}
+		return errors.Wrap(iter.Error(), "unable to iterate through layer")
+	}
+	for i := range d.Chunks {
+		c := &d.Chunks[i]
+		if c.Width <= 0 {
+			return errors.Errorf("invalid width %d of chunk (%v, %v)", c.Width, c.X, c.Y)
+		}
+		iter, err := d.ChunkIter(c)
+		if err != nil {
+			return errors.Wrap(err, "unable to load chunk iterator")
+		}
+		for iter.Next() {
+			j := int(iter.GetIndex())
+			fn(int(c.X)+j%c.Width, int(c.Y)+j/c.Width, iter.Get())
+		}
+		if err := iter.Error(); err != nil {
+			return errors.Wrapf(err, "unable to iterate through chunk (%v, %v)", c.X, c.Y)
+		}
+	}
+	return nil
+}
+
+// Bounds returns the area covered by the chunks of an infinite map in tiles.
+// ok is false if the data is not stored in chunks.
+func (d *Data) Bounds() (x, y, w, h int, ok bool) {
+	if len(d.Chunks) == 0 {
+		return 0, 0, 0, 0, false
+	}
+	minX, minY := int(d.Chunks[0].X), int(d.Chunks[0].Y)
+	maxX, maxY := minX+d.Chunks[0].Width, minY+d.Chunks[0].Height
+	for _, c := range d.Chunks[1:] {
+		if int(c.X) < minX {
+			minX = int(c.X)
+		}
+		if int(c.Y) < minY {
+			minY = int(c.Y)
+		}
+		if int(c.X)+c.Width > maxX {
+			maxX = int(c.X) + c.Width
+		}
+		if int(c.Y)+c.Height > maxY {
+			maxY = int(c.Y) + c.Height
+		}
+	}
+	return minX, minY, maxX - minX, maxY - minY, true
+}
+
 func (d *Data) Tiles() ([]TileInstance, error) {
 	iter, err := d.Iter()
 	if err != nil {
@@ -192,5 +302,5 @@ func (d *Data) Tiles() ([]TileInstance, error) {
 	for iter.Next() {
 		tis = append(tis, iter.Get())
 	}
-	return tis, errors.Wrap(err, "error reading iterator")
+	return tis, errors.Wrap(iter.Error(), "error reading iterator")
 }
@@ -0,0 +1,36 @@
+package tmx
+
+// ParallaxOrigin returns the point of the map, in pixels, at which the
+// camera position results in no parallax shift for any layer.
+func (m *Map) ParallaxOrigin() (float64, float64) {
+	x, y := 0.0, 0.0
+	if m.ParallaxOriginX != nil {
+		x = *m.ParallaxOriginX
+	}
+	if m.ParallaxOriginY != nil {
+		y = *m.ParallaxOriginY
+	}
+	return x, y
+}
+
+// Parallax returns the parallax factors of the layer itself, without those
+// of its parent groups. Unset factors default to 1.
+func (l *Layer) Parallax() (float64, float64) {
+	x, y := 1.0, 1.0
+	if l.ParallaxX != nil {
+		x = *l.ParallaxX
+	}
+	if l.ParallaxY != nil {
+		y = *l.ParallaxY
+	}
+	return x, y
+}
+
+// ParallaxOffset returns the distance a layer with the effective parallax
+// factors (fx, fy) is moved by when the camera looks at (camX, camY). Like
+// in Tiled, a factor of 1 moves the layer with the map, a factor of 0 keeps
+// it fixed on the screen.
+func (m *Map) ParallaxOffset(fx, fy, camX, camY float64) (float64, float64) {
+	ox, oy := m.ParallaxOrigin()
+	return (camX - ox) * (1 - fx), (camY - oy) * (1 - fy)
+}
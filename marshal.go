@@ -0,0 +1,140 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// MarshalXML implements xml.Marshaler. The raw inner xml of the data is only
+// written for encoded data, as it would otherwise duplicate the <tile> and
+// <chunk> elements.
+func (d *Data) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	err := d.Flush()
+	if err != nil {
+		return err
+	}
+	start.Attr = nil
+	if d.Encoding != nil {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "encoding"}, Value: *d.Encoding})
+	}
+	if d.Compression != nil {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "compression"}, Value: *d.Compression})
+	}
+	err = e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(d.Chunks) > 0:
+		for i := range d.Chunks {
+			err = e.EncodeElement(&d.Chunks[i], xml.StartElement{Name: xml.Name{Local: "chunk"}})
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		err = encodeTiles(e, d.TileData, d.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML implements xml.Marshaler, see Data.MarshalXML.
+func (c *Chunk) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = []xml.Attr{
+		{Name: xml.Name{Local: "x"}, Value: fmt.Sprint(c.X)},
+		{Name: xml.Name{Local: "y"}, Value: fmt.Sprint(c.Y)},
+		{Name: xml.Name{Local: "width"}, Value: fmt.Sprint(c.Width)},
+		{Name: xml.Name{Local: "height"}, Value: fmt.Sprint(c.Height)},
+	}
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	err = encodeTiles(e, c.TileData, c.Data)
+	if err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+func encodeTiles(e *xml.Encoder, tiles []TileData, data []byte) error {
+	if len(tiles) == 0 {
+		return e.EncodeToken(xml.CharData(data))
+	}
+	for i := range tiles {
+		err := e.EncodeElement(&tiles[i], xml.StartElement{Name: xml.Name{Local: "tile"}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TerrainTypes is the <terraintypes> element of a tileset. It is only
+// written if the tileset has terrain types.
+type TerrainTypes []*Terrain
+
+type terrainTypesXML struct {
+	Terrains []*Terrain `xml:"terrain"`
+}
+
+// MarshalXML implements xml.Marshaler.
+func (tt TerrainTypes) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(terrainTypesXML{Terrains: tt}, start)
+}
+
+// UnmarshalXML implements xml.Unmarshaler.
+func (tt *TerrainTypes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v terrainTypesXML
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	*tt = append(*tt, v.Terrains...)
+	return nil
+}
+
+// isLayer returns true for the elements of the layers of a map or group.
+// Unknown elements caught by the ",any" fields are not written back.
+func isLayer(l *Layer) bool {
+	switch l.XMLName.Local {
+	case "layer", "objectgroup", "imagelayer", "group":
+		return true
+	}
+	return false
+}
+
+func filterLayers(layers []*Layer) []*Layer {
+	var filtered []*Layer
+	for _, l := range layers {
+		if isLayer(l) {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
+}
+
+// MarshalXML implements xml.Marshaler. The map is always written as a <map>
+// element, followed by its properties, tilesets and layers.
+func (m *Map) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type mapXML Map
+	cp := mapXML(*m)
+	cp.Layers = filterLayers(m.Layers)
+	start.Name = xml.Name{Local: "map"}
+	return e.EncodeElement(&cp, start)
+}
+
+// MarshalXML implements xml.Marshaler. The layer is written as the element
+// it was decoded from, only the child layers of groups are written.
+func (l *Layer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type layerXML Layer
+	cp := layerXML(*l)
+	cp.Layers = filterLayers(l.Layers)
+	if l.XMLName.Local != "" {
+		start.Name = l.XMLName
+	}
+	return e.EncodeElement(&cp, start)
+}
@@ -0,0 +1,304 @@
+package tmx
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// MergeOptions configures Merge.
+type MergeOptions struct {
+	EmptyTiles  bool // Copy empty tiles as well, which clears the tiles of dst they are copied onto.
+	SkipObjects bool // Do not copy the objects of object groups.
+}
+
+// Merge copies the tile layers and object groups of src into dst, moved by
+// (atX, atY) in tiles. Layers are matched by name and added to dst if it has
+// no layer of that name. Copied objects get new IDs. The tilesets of src are
+// unified with those of dst: external tilesets with the same source, and
+// embedded tilesets with the same name and image, are shared, all others are
+// added to dst. Tiles copied outside of a fixed-size dst, and tiles of src
+// that have no tile in the tilesets of dst, are dropped. Image layers are not
+// copied. Both maps must have the same tile size. The tile layers of both
+// maps are decoded before dst is changed, so dst is left as it was if one of
+// them can't be read.
+func Merge(dst, src *Map, atX, atY int, opts *MergeOptions) error {
+	if opts == nil {
+		opts = &MergeOptions{}
+	}
+	if dst.TileWidth != src.TileWidth || dst.TileHeight != src.TileHeight {
+		return errors.Errorf("tile size %dx%d differs from %dx%d", src.TileWidth, src.TileHeight, dst.TileWidth, dst.TileHeight)
+	}
+
+	var layers []*Layer
+	src.walkLayers(func(l, parent *Layer) {
+		layers = append(layers, l)
+	})
+	tiles := make(map[*Layer][]placedTile)
+	for _, l := range layers {
+		if l.XMLName.Local != "layer" || l.Data == nil {
+			continue
+		}
+		placed, err := dst.decodeMerged(l, int(src.Width), atX, atY, opts)
+		if err != nil {
+			return errors.Wrapf(err, "unable to merge layer '%s'", l.Name)
+		}
+		tiles[l] = placed
+	}
+
+	gids, err := dst.unifyTileSets(src)
+	if err != nil {
+		return errors.Wrap(err, "unable to merge tilesets")
+	}
+	remap := func(tile TileInstance) TileInstance {
+		gid := gids(tile.GID())
+		if gid == 0 {
+			return 0
+		}
+		return TileInstance(uint32(tile)&^GIDMask | gid)
+	}
+	for _, l := range layers {
+		switch {
+		case l.XMLName.Local == "layer" && l.Data != nil:
+			err = dst.mergeTiles(l, tiles[l], opts, remap)
+		case l.XMLName.Local == "objectgroup" && !opts.SkipObjects:
+			err = dst.mergeObjects(l, atX, atY, remap)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to merge layer '%s'", l.Name)
+		}
+	}
+	return nil
+}
+
+// findLayerByName returns the first layer with the given name and type.
+func (m *Map) findLayerByName(name, kind string) *Layer {
+	var found *Layer
+	m.walkLayers(func(l, parent *Layer) {
+		if found == nil && l.Name == name && l.XMLName.Local == kind {
+			found = l
+		}
+	})
+	return found
+}
+
+// sameTileSet returns true if two tilesets of different maps are the same.
+func sameTileSet(a, b *TileSet) bool {
+	if a.Source != "" || b.Source != "" {
+		return a.Source == b.Source
+	}
+	if a.Name != b.Name || (a.Image == nil) != (b.Image == nil) {
+		return false
+	}
+	return a.Image == nil || a.Image.Source == b.Image.Source
+}
+
+// unifyTileSets adds the tilesets of src missing in m and returns a mapping
+// of the global tile IDs of src to those of m.
+func (m *Map) unifyTileSets(src *Map) (func(gid uint32) uint32, error) {
+	targets := make(map[*TileSet]*TileSet)
+	for _, ts := range src.TileSets {
+		for _, own := range m.TileSets {
+			if sameTileSet(ts, own) {
+				targets[ts] = own
+				break
+			}
+		}
+		if targets[ts] == nil {
+			cp := deepCopy(ts).(*TileSet)
+			err := m.AddTileSet(cp)
+			if err != nil {
+				return nil, err
+			}
+			targets[ts] = cp
+		}
+	}
+	return func(gid uint32) uint32 {
+		ts := src.TileSetFor(gid)
+		if ts == nil {
+			return 0
+		}
+		local := gid - ts.FirstGID
+		to := targets[ts]
+		if local >= to.Span() {
+			return 0
+		}
+		return to.FirstGID + local
+	}, nil
+}
+
+// placedTile is a tile of another map at its position in m.
+type placedTile struct {
+	x, y int
+	tile TileInstance
+}
+
+// decodeMerged returns the tiles of a tile layer of another map, which is
+// width tiles wide, moved by (atX, atY). It also checks that the tile layer
+// of the same name in m, if any, can be decoded.
+func (m *Map) decodeMerged(l *Layer, width, atX, atY int, opts *MergeOptions) ([]placedTile, error) {
+	if l.Width != nil {
+		width = int(*l.Width)
+	}
+	var tiles []placedTile
+	err := l.Data.ForEach(width, func(x, y int, tile TileInstance) {
+		if tile.GID() != 0 || opts.EmptyTiles {
+			tiles = append(tiles, placedTile{x + atX, y + atY, tile})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	target := m.findLayerByName(l.Name, "layer")
+	if target != nil && target.Data != nil {
+		dstWidth := int(m.Width)
+		if target.Width != nil {
+			dstWidth = int(*target.Width)
+		}
+		err = target.Data.ForEach(dstWidth, func(x, y int, tile TileInstance) {})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tiles, nil
+}
+
+// mergeTiles copies the tiles of a tile layer of another map, decoded by
+// decodeMerged, into the tile layer of the same name.
+func (m *Map) mergeTiles(l *Layer, placed []placedTile, opts *MergeOptions, remap func(TileInstance) TileInstance) error {
+	var tiles []placedTile
+	for _, p := range placed {
+		tile := remap(p.tile)
+		if tile.GID() == 0 && !opts.EmptyTiles {
+			continue
+		}
+		tiles = append(tiles, placedTile{p.x, p.y, tile})
+	}
+
+	infinite := m.Infinite != nil && *m.Infinite != 0
+	target := m.findLayerByName(l.Name, "layer")
+	if target == nil {
+		target = NewTileLayer(l.Name)
+		if infinite && len(tiles) > 0 {
+			target.Data = &Data{Encoding: l.Data.Encoding, Compression: l.Data.Compression}
+			target.Data.Chunks = []Chunk{emptyChunk(tiles[0].x, tiles[0].y)}
+			err := target.Data.SetTile(0, tiles[0].x, tiles[0].y, 0)
+			if err != nil {
+				return err
+			}
+		}
+		err := m.AddLayer(nil, -1, target)
+		if err != nil {
+			return err
+		}
+	}
+	if target.Data == nil {
+		return nil
+	}
+
+	if len(target.Data.Chunks) > 0 {
+		for _, p := range tiles {
+			err := target.Data.SetTile(0, p.x, p.y, p.tile)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	dstWidth, dstHeight := int(m.Width), int(m.Height)
+	if target.Width != nil && target.Height != nil {
+		dstWidth, dstHeight = int(*target.Width), int(*target.Height)
+	}
+	all, err := target.Data.Tiles()
+	if err != nil {
+		return err
+	}
+	for _, p := range tiles {
+		i := p.y*dstWidth + p.x
+		if p.x >= 0 && p.x < dstWidth && p.y >= 0 && p.y < dstHeight && i < len(all) {
+			all[i] = p.tile
+		}
+	}
+	return target.Data.Encode(dstWidth, all)
+}
+
+// emptyChunk returns an empty chunk of Tiled's default chunk size holding
+// the tile at (x, y).
+func emptyChunk(x, y int) Chunk {
+	const size = 16
+	return Chunk{
+		X:      math.Floor(float64(x)/size) * size,
+		Y:      math.Floor(float64(y)/size) * size,
+		Width:  size,
+		Height: size,
+	}
+}
+
+// mergeObjects copies the objects of an object group of another map into
+// the object group of the same name.
+func (m *Map) mergeObjects(l *Layer, atX, atY int, remap func(TileInstance) TileInstance) error {
+	target := m.findLayerByName(l.Name, "objectgroup")
+	if target == nil {
+		target = NewObjectGroup(l.Name)
+		target.Color = l.Color
+		target.DrawOrder = l.DrawOrder
+		err := m.AddLayer(nil, -1, target)
+		if err != nil {
+			return err
+		}
+	}
+	for _, obj := range l.Objects {
+		cp := deepCopy(obj).(*Object)
+		cp.X += float64(atX) * float64(m.TileWidth)
+		cp.Y += float64(atY) * float64(m.TileHeight)
+		if obj.GID != nil {
+			cp.GID = tileGID(remap(TileInstance(*obj.GID)))
+		}
+		err := m.AddObject(target, cp)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deepCopy returns a copy of a pointer to a map element, which shares no
+// pointers or slices with the original.
+func deepCopy(v interface{}) interface{} {
+	src := reflect.ValueOf(v)
+	dst := reflect.New(src.Type()).Elem()
+	copyValue(dst, src)
+	return dst.Interface()
+}
+
+// copyValue sets dst to a deep copy of src.
+func copyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		cp := reflect.New(src.Type().Elem())
+		copyValue(cp.Elem(), src.Elem())
+		dst.Set(cp)
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		cp := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			copyValue(cp.Index(i), src.Index(i))
+		}
+		dst.Set(cp)
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				copyValue(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
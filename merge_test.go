@@ -0,0 +1,131 @@
+package tmx
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	dst := &Map{Width: 4, Height: 4, TileWidth: 16, TileHeight: 16}
+	require.NoError(t, dst.AddTileSet(&TileSet{Source: "a.tsx", TileCount: 4}))
+	ground := NewTileLayer("ground")
+	require.NoError(t, dst.AddLayer(nil, -1, ground))
+	things := NewObjectGroup("things")
+	require.NoError(t, dst.AddLayer(nil, -1, things))
+	require.NoError(t, dst.AddObject(things, &Object{Name: "dst"}))
+
+	src := &Map{Width: 2, Height: 2, TileWidth: 16, TileHeight: 16}
+	require.NoError(t, src.AddTileSet(&TileSet{Name: "b", TileCount: 2, Image: &Image{Source: "b.png"}}))
+	require.NoError(t, src.AddTileSet(&TileSet{Source: "a.tsx", TileCount: 4}))
+	srcGround := NewTileLayer("ground")
+	require.NoError(t, src.AddLayer(nil, -1, srcGround))
+	require.NoError(t, srcGround.Data.Encode(2, []TileInstance{1, TileInstance(FlippedHorizontallyFlag | 3), 0, 4}))
+	srcThings := NewObjectGroup("things")
+	require.NoError(t, src.AddLayer(nil, -1, srcThings))
+	gid := uint32(2)
+	require.NoError(t, src.AddObject(srcThings, &Object{Name: "src", X: 1, Y: 2, GID: &gid}))
+	require.NoError(t, src.AddLayer(nil, -1, NewObjectGroup("extra")))
+
+	require.NoError(t, Merge(dst, src, 1, 2, nil))
+	require.Len(t, dst.TileSets, 2)
+	assert.Equal(t, "b", dst.TileSets[1].Name)
+	assert.EqualValues(t, 5, dst.TileSets[1].FirstGID)
+
+	tiles, err := ground.Data.Tiles()
+	require.NoError(t, err)
+	assert.Equal(t, []TileInstance{
+		0, 0, 0, 0,
+		0, 0, 0, 0,
+		0, 5, TileInstance(FlippedHorizontallyFlag | 1), 0,
+		0, 0, 2, 0,
+	}, tiles)
+
+	require.Len(t, things.Objects, 2)
+	obj := things.Objects[1]
+	assert.EqualValues(t, 2, obj.ID)
+	assert.Equal(t, 17.0, obj.X)
+	assert.Equal(t, 34.0, obj.Y)
+	assert.EqualValues(t, 6, *obj.GID)
+	assert.NotNil(t, dst.findLayerByName("extra", "objectgroup"))
+
+	// objects are copied, not shared
+	assert.EqualValues(t, 1, src.Layers[1].Objects[0].ID)
+
+	assert.Error(t, Merge(dst, &Map{TileWidth: 8, TileHeight: 8}, 0, 0, nil))
+
+	// tiles without a tileset don't clear the tiles of dst
+	require.NoError(t, srcGround.Data.Encode(2, []TileInstance{9, 0, 0, 0}))
+	require.NoError(t, Merge(dst, src, 2, 3, nil))
+	tiles, err = ground.Data.Tiles()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, tiles[14])
+
+	// a layer that can't be decoded leaves dst unchanged
+	bad := NewTileLayer("bad")
+	require.NoError(t, src.AddLayer(nil, -1, bad))
+	bad.Data.Data = []byte("1,x,0,0")
+	src.TileSets = append(src.TileSets, &TileSet{Name: "c", FirstGID: 10, TileCount: 1})
+	assert.Error(t, Merge(dst, src, 0, 0, nil))
+	assert.Len(t, dst.TileSets, 2)
+	assert.Len(t, things.Objects, 3)
+	assert.Nil(t, dst.findLayerByName("bad", "layer"))
+}
+
+func TestMergeTwice(t *testing.T) {
+	room := &Map{Width: 1, Height: 1, TileWidth: 16, TileHeight: 16}
+	require.NoError(t, room.AddTileSet(&TileSet{
+		Name:      "room",
+		TileCount: 1,
+		Tiles:     []*Tile{{ID: 0, Image: &Image{Source: "room.png"}}},
+	}))
+	things := NewObjectGroup("things")
+	require.NoError(t, room.AddLayer(nil, -1, things))
+	require.NoError(t, room.AddObject(things, &Object{
+		Polygon:    &Polygon{Points: "0,0 10,0 10,5"},
+		Properties: &Properties{Properties: []Property{{Name: "kind", Value: "wall"}}},
+	}))
+
+	dst := &Map{Width: 2, Height: 1, TileWidth: 16, TileHeight: 16}
+	require.NoError(t, Merge(dst, room, 0, 0, nil))
+	require.NoError(t, Merge(dst, room, 1, 0, nil))
+	objs := dst.Layers[0].Objects
+	require.Len(t, objs, 2)
+
+	// every copy can be edited on its own
+	objs[0].Polygon.Points = "0,0 -10,0 -10,5"
+	objs[0].Properties.Properties[0].Value = "door"
+	dst.TileSets[0].Tiles[0].Image.Source = "other.png"
+	assert.Equal(t, "0,0 10,0 10,5", objs[1].Polygon.Points)
+	assert.Equal(t, "wall", objs[1].Properties.Properties[0].Value)
+	assert.Equal(t, "0,0 10,0 10,5", things.Objects[0].Polygon.Points)
+	assert.Equal(t, "wall", things.Objects[0].Properties.Properties[0].Value)
+	assert.Equal(t, "room.png", room.TileSets[0].Tiles[0].Image.Source)
+}
+
+func TestMergeInfinite(t *testing.T) {
+	fp, err := os.Open("resources/modern.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	dst, err := Load(fp)
+	require.NoError(t, err)
+
+	src := &Map{Width: 2, Height: 1, TileWidth: dst.TileWidth, TileHeight: dst.TileHeight}
+	require.NoError(t, src.AddTileSet(&TileSet{Name: "other", TileCount: 1}))
+	walls := NewTileLayer("walls")
+	require.NoError(t, src.AddLayer(nil, -1, walls))
+	require.NoError(t, walls.Data.Encode(2, []TileInstance{1, 1}))
+
+	require.NoError(t, Merge(dst, src, -20, 3, &MergeOptions{SkipObjects: true}))
+	layer := dst.findLayerByName("walls", "layer")
+	require.NotNil(t, layer)
+	tiles := make(map[[2]int]uint32)
+	require.NoError(t, layer.Data.ForEach(0, func(x, y int, tile TileInstance) {
+		if tile.GID() != 0 {
+			tiles[[2]int{x, y}] = tile.GID()
+		}
+	}))
+	assert.Equal(t, map[[2]int]uint32{{-20, 3}: 26, {-19, 3}: 26}, tiles)
+}
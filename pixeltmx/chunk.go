@@ -0,0 +1,100 @@
+package pixeltmx
+
+import (
+	"container/list"
+
+	"github.com/faiface/pixel"
+)
+
+// Chunk cache defaults.
+const (
+	DefaultChunkSize   = 32       // tiles along each side of a render chunk
+	DefaultChunkBudget = 64 << 20 // bytes of chunk triangles kept in memory
+)
+
+// chunkKey identifies a render chunk of a tile layer by its position in
+// chunks from the map origin.
+type chunkKey struct {
+	layer *tileLayerDrawer
+	x, y  int
+}
+
+// trianglesVertexSize is the memory used by a vertex of pixel.TrianglesData.
+const trianglesVertexSize = 72
+
+// renderChunk is a part of a tile layer with its triangles cached per
+// tileset picture.
+type renderChunk struct {
+	key     chunkKey
+	drawers []*pixel.Drawer
+	size    int // the memory used by the triangles in bytes
+}
+
+// chunkCache keeps the most recently drawn render chunks of all layers of a
+// map, the least recently used chunks are dropped once the triangles exceed
+// the memory budget.
+type chunkCache struct {
+	used   int
+	lru    *list.List // of *renderChunk, most recently used first
+	chunks map[chunkKey]*list.Element
+}
+
+func newChunkCache() *chunkCache {
+	return &chunkCache{
+		lru:    list.New(),
+		chunks: make(map[chunkKey]*list.Element),
+	}
+}
+
+// get returns a cached chunk and marks it as used.
+func (cc *chunkCache) get(key chunkKey) (*renderChunk, bool) {
+	e, exists := cc.chunks[key]
+	if !exists {
+		return nil, false
+	}
+	cc.lru.MoveToFront(e)
+	return e.Value.(*renderChunk), true
+}
+
+// put adds a chunk and evicts the least recently used chunks until the
+// triangles fit in the budget. The added chunk itself is never evicted.
+func (cc *chunkCache) put(rc *renderChunk, budget int) {
+	cc.remove(rc.key)
+	cc.chunks[rc.key] = cc.lru.PushFront(rc)
+	cc.used += rc.size
+	for cc.used > budget && cc.lru.Len() > 1 {
+		cc.evict(cc.lru.Back())
+	}
+}
+
+// remove drops a chunk if it is cached.
+func (cc *chunkCache) remove(key chunkKey) {
+	if e, exists := cc.chunks[key]; exists {
+		cc.evict(e)
+	}
+}
+
+// removeLayer drops all chunks of a layer.
+func (cc *chunkCache) removeLayer(layer *tileLayerDrawer) {
+	for key, e := range cc.chunks {
+		if key.layer == layer {
+			cc.evict(e)
+		}
+	}
+}
+
+func (cc *chunkCache) evict(e *list.Element) {
+	rc := cc.lru.Remove(e).(*renderChunk)
+	delete(cc.chunks, rc.key)
+	cc.used -= rc.size
+}
+
+// floorDiv divides rounding towards negative infinity, so that negative
+// tile positions of infinite maps fall into the right chunk.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
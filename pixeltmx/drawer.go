@@ -19,12 +19,82 @@ const (
 // underlying type can be extracted using `Type()` method. Each Layer will be updated once
 // on creation and remain cached for subsequent draws. If the underlying data or resources have
 // been changed, the `Update()` method must be called before the changes will be visible when
-// drawing.
+// drawing. Single tiles of tile layers can be changed more cheaply with `SetTile()` (see
+// TileLayer).
 type Drawer interface {
 	Type() int
 	Info() *LayerInfo
 	Update() error
-	Draw(target pixel.Target)
+	Draw(target pixel.Target, view View)
+}
+
+// TileLayer is implemented by the drawers of tile layers. SetTile changes
+// the tile at position (x, y) in map tiles in both the layer data and the
+// drawer, without updating the whole layer.
+type TileLayer interface {
+	Drawer
+	SetTile(x, y int, tile tmx.TileInstance) error
+}
+
+// FindDrawer returns the drawer of the layer with the given ID within the
+// drawer tree, or nil if there is none.
+func FindDrawer(root Drawer, id uint32) Drawer {
+	if root.Info().ID() == id {
+		return root
+	}
+	if gd, ok := root.(*groupDrawer); ok {
+		for _, child := range gd.children {
+			if d := FindDrawer(child, id); d != nil {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// View describes how the map is looked at when it is drawn. Layers with a
+// parallax factor other than 1 are moved relative to the camera by setting
+// the matrix of the target, this requires the target to be a
+// pixel.BasicTarget (e.g. a *pixelgl.Window or *pixel.Batch). Other targets
+// are drawn to without parallax.
+type View struct {
+	Matrix pixel.Matrix // The matrix of the target, it is restored after each layer. The zero value is treated as pixel.IM.
+	Camera pixel.Vec    // The point the camera is centered on in pixel world coordinates.
+	Bounds pixel.Rect   // The visible area in pixel world coordinates, tiles and objects outside of it are skipped. The zero value draws everything.
+}
+
+// visible returns the visible area in the coordinates of the layer, which is
+// moved by its parallax offset. cull is false if everything should be drawn.
+func (v View) visible(info *LayerInfo) (r pixel.Rect, cull bool) {
+	if v.Bounds == (pixel.Rect{}) {
+		return pixel.Rect{}, false
+	}
+	off := info.ParallaxOffset(v.Camera)
+	return pixel.R(
+		v.Bounds.Min.X-off.X, v.Bounds.Min.Y-off.Y,
+		v.Bounds.Max.X-off.X, v.Bounds.Max.Y-off.Y,
+	), true
+}
+
+func (v View) matrix() pixel.Matrix {
+	if v.Matrix == (pixel.Matrix{}) {
+		return pixel.IM
+	}
+	return v.Matrix
+}
+
+// apply moves the target by the parallax offset of the layer.
+func (v View) apply(t pixel.Target, info *LayerInfo) {
+	if bt, ok := t.(pixel.BasicTarget); ok {
+		bt.SetMatrix(pixel.IM.Moved(info.ParallaxOffset(v.Camera)).Chained(v.matrix()))
+	}
+}
+
+// reset restores the matrix of the target after a layer has been drawn.
+func (v View) reset(t pixel.Target) {
+	if bt, ok := t.(pixel.BasicTarget); ok {
+		bt.SetMatrix(v.matrix())
+	}
 }
 
 // NewDrawer creates a Drawer which will render the layer and recursively draw all child layers.
@@ -49,13 +119,15 @@ func NewDrawer(resources *Resources, parent Drawer, layer *tmx.Layer) (Drawer, e
 // NewRootDrawer will create a special Drawer that will recursively draw the entire tmx map.
 func NewRootDrawer(resources *Resources, mapData *tmx.Map) (Drawer, error) {
 	info := &LayerInfo{
-		mapData: mapData,
-		layer:   nil,
-		w:       int(mapData.Width),
-		h:       int(mapData.Height),
-		offX:    0.0,
-		offY:    0.0,
-		color:   pixel.Alpha(1.0),
+		mapData:   mapData,
+		layer:     nil,
+		w:         int(mapData.Width),
+		h:         int(mapData.Height),
+		offX:      0.0,
+		offY:      0.0,
+		parallaxX: 1.0,
+		parallaxY: 1.0,
+		color:     pixel.Alpha(1.0),
 	}
 	gd := &groupDrawer{
 		info:     info,
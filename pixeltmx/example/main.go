@@ -65,8 +65,10 @@ func run() {
 		}
 		viewMatrix = pixel.IM.Moved(win.Bounds().Center().Sub(cameraOrigin)).Scaled(pixel.ZV, scale)
 		win.Clear(colornames.Gray)
-		win.SetMatrix(viewMatrix)
-		drawer.Draw(win)
+		min := viewMatrix.Unproject(win.Bounds().Min)
+		max := viewMatrix.Unproject(win.Bounds().Max)
+		visible := pixel.R(min.X, min.Y, max.X, max.Y)
+		drawer.Draw(win, pixeltmx.View{Matrix: viewMatrix, Camera: cameraOrigin, Bounds: visible})
 		win.Update()
 		frames++
 		select {
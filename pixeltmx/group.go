@@ -33,9 +33,9 @@ func (gd *groupDrawer) Info() *LayerInfo {
 	return gd.info
 }
 
-func (gd *groupDrawer) Draw(target pixel.Target) {
+func (gd *groupDrawer) Draw(target pixel.Target, view View) {
 	for _, child := range gd.children {
-		child.Draw(target)
+		child.Draw(target, view)
 	}
 }
 
@@ -1,7 +1,7 @@
 package pixeltmx
 
 import (
-	"path/filepath"
+	"math"
 
 	"github.com/faiface/pixel"
 	"github.com/pkg/errors"
@@ -12,6 +12,7 @@ type imageLayerDrawer struct {
 	source    string
 	info      *LayerInfo
 	sprite    *pixel.Sprite
+	positions []pixel.Vec
 }
 
 func newImageLayerDriver(resources *Resources, info *LayerInfo) (*imageLayerDrawer, error) {
@@ -22,11 +23,7 @@ func newImageLayerDriver(resources *Resources, info *LayerInfo) (*imageLayerDraw
 		resources: resources,
 		info:      info,
 	}
-	if filepath.IsAbs(info.layer.Image.Source) {
-		ild.source = filepath.Clean(info.layer.Image.Source)
-	} else {
-		ild.source = filepath.Join(resources.path, info.layer.Image.Source)
-	}
+	ild.source = info.layer.Image.Key(resources.path)
 
 	return ild, ild.Update()
 }
@@ -40,17 +37,56 @@ func (ild *imageLayerDrawer) Info() *LayerInfo {
 }
 
 func (ild *imageLayerDrawer) Update() error {
-	pic := ild.resources.images[ild.source]
+	pic, exists := ild.resources.images[ild.source]
+	if !exists {
+		return errors.Errorf("image source '%s' not found", ild.source)
+	}
 	ild.sprite = pixel.NewSprite(pic, pic.Bounds())
+
+	// repeated images are tiled over the whole map area
+	w := ild.sprite.Frame().W()
+	h := ild.sprite.Frame().H()
+	xs := repeatPositions(ild.info.offX, w, ild.info.layer.RepeatX, float64(ild.info.mapData.Width*ild.info.mapData.TileWidth))
+	ys := repeatPositions(ild.info.offY, h, ild.info.layer.RepeatY, float64(ild.info.mapData.Height*ild.info.mapData.TileHeight))
+	ild.positions = ild.positions[:0]
+	for _, y := range ys {
+		for _, x := range xs {
+			ild.positions = append(ild.positions, ild.info.TMXToPixelRect(x, y, w, h).Center())
+		}
+	}
 	return nil
 }
 
-func (ild *imageLayerDrawer) Draw(t pixel.Target) {
-	vec := ild.info.TMXToPixelRect(
-		ild.info.offX,
-		ild.info.offY,
-		ild.sprite.Frame().W(),
-		ild.sprite.Frame().H(),
-	).Center()
-	ild.sprite.DrawColorMask(t, pixel.IM.Moved(vec), ild.info.color)
+// repeatPositions returns the positions along one axis at which an image of
+// the given size is drawn. Without repeat this is only the layer offset.
+func repeatPositions(offset, size float64, repeat *int, length float64) []float64 {
+	if repeat == nil || *repeat == 0 || size <= 0 {
+		return []float64{offset}
+	}
+	start := math.Mod(offset, size)
+	if start > 0 {
+		start -= size
+	}
+	var positions []float64
+	for p := start; p < length; p += size {
+		positions = append(positions, p)
+	}
+	return positions
+}
+
+// Draw draws the repeats of the image intersecting the visible bounds of
+// the view.
+func (ild *imageLayerDrawer) Draw(t pixel.Target, view View) {
+	visible, cull := view.visible(ild.info)
+	w := ild.sprite.Frame().W()
+	h := ild.sprite.Frame().H()
+	view.apply(t, ild.info)
+	for _, vec := range ild.positions {
+		rect := pixel.R(vec.X-w/2, vec.Y-h/2, vec.X+w/2, vec.Y+h/2)
+		if cull && !rect.Intersects(visible) {
+			continue
+		}
+		ild.sprite.DrawColorMask(t, pixel.IM.Moved(vec), ild.info.color)
+	}
+	view.reset(t)
 }
@@ -1,8 +1,8 @@
 package pixeltmx
 
 import (
-	"strconv"
-	"strings"
+	"image"
+	"math"
 
 	"github.com/elliotmr/tmx"
 	"github.com/faiface/pixel"
@@ -10,17 +10,21 @@ import (
 )
 
 // LayerInfo provides drawing information for the layer, it holds the
-// recursively calculated offset, visibility, and color information, as
-// well as a reference to the base map data. It prevides easy methods
+// recursively calculated offset, parallax, visibility, and color information,
+// as well as a reference to the base map data. It prevides easy methods
 // translating between tmx and pixel world coordinates.
 type LayerInfo struct {
-	mapData *tmx.Map
-	layer   *tmx.Layer
-	w       int
-	h       int
-	offX    float64
-	offY    float64
-	color   pixel.RGBA
+	mapData   *tmx.Map
+	layer     *tmx.Layer
+	x0        int
+	y0        int
+	w         int
+	h         int
+	offX      float64
+	offY      float64
+	parallaxX float64
+	parallaxY float64
+	color     pixel.RGBA
 }
 
 func newLayerInfo(parent *LayerInfo, layer *tmx.Layer) (*LayerInfo, error) {
@@ -31,20 +35,87 @@ func newLayerInfo(parent *LayerInfo, layer *tmx.Layer) (*LayerInfo, error) {
 	offX, offY := extractLayerOffsets(layer)
 	li.offX += offX
 	li.offY += offY
-	c, err := extractLayerColor(layer)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to extract color")
-	}
-	li.color = li.color.Mul(c)
+	// tints and opacities multiply down the layer tree
+	li.color = li.color.Mul(extractLayerColor(layer))
+	px, py := layer.Parallax()
+	li.parallaxX *= px
+	li.parallaxY *= py
 	if layer.Width != nil {
 		li.w = int(*layer.Width)
 	}
 	if layer.Height != nil {
 		li.h = int(*layer.Height)
 	}
+	if layer.Data != nil {
+		// tile layers of infinite maps cover the area of their chunks.
+		if x, y, w, h, ok := layer.Data.Bounds(); ok {
+			li.x0, li.y0, li.w, li.h = x, y, w, h
+		}
+	}
 	return li, nil
 }
 
+// ID returns the unique ID of the layer, or 0 for the root of the map.
+func (li *LayerInfo) ID() uint32 {
+	if li.layer == nil {
+		return 0
+	}
+	return li.layer.ID
+}
+
+// Class returns the class of the layer.
+func (li *LayerInfo) Class() string {
+	if li.layer == nil || li.layer.Class == nil {
+		return ""
+	}
+	return *li.layer.Class
+}
+
+// Parallax returns the effective parallax factors of the layer, which are
+// the factors of the layer multiplied with those of all its parent groups.
+func (li *LayerInfo) Parallax() (float64, float64) {
+	return li.parallaxX, li.parallaxY
+}
+
+// ParallaxOffset returns the distance in pixel world coordinates the layer is
+// moved by when the camera is centered on camera (in pixel world coordinates).
+func (li *LayerInfo) ParallaxOffset(camera pixel.Vec) pixel.Vec {
+	// the y-axis flip is its own inverse, so this yields TMX coordinates
+	c := li.TMXToPixelVec(camera.X, camera.Y)
+	dx, dy := li.mapData.ParallaxOffset(li.parallaxX, li.parallaxY, c.X, c.Y)
+	return pixel.V(dx, -dy)
+}
+
+// Bounds returns the area covered by the layer in tiles. The origin is only
+// different from (0, 0) for tile layers of infinite maps.
+func (li *LayerInfo) Bounds() (x, y, w, h int) {
+	return li.x0, li.y0, li.w, li.h
+}
+
+// cellRange returns the cells of the layer, relative to the origin of the
+// layer bounds, whose tiles intersect the rectangle r in pixel world
+// coordinates. The margins tell how far tile images reach below (min) and
+// above (max) their cell in pixel world coordinates.
+func (li *LayerInfo) cellRange(r pixel.Rect, marginMin, marginMax pixel.Vec) image.Rectangle {
+	tw := float64(li.mapData.TileWidth)
+	th := float64(li.mapData.TileHeight)
+	// the top of the visible area has the smallest TMX y
+	top := li.TMXToPixelVec(r.Min.X-marginMax.X, r.Max.Y-marginMin.Y)
+	bottom := li.TMXToPixelVec(r.Max.X-marginMin.X, r.Min.Y-marginMax.Y)
+	cells := image.Rect(
+		int(math.Floor(top.X/tw))-li.x0,
+		int(math.Floor(top.Y/th))-li.y0,
+		int(math.Ceil(bottom.X/tw))-li.x0,
+		int(math.Ceil(bottom.Y/th))-li.y0,
+	)
+	return cells.Intersect(image.Rect(0, 0, li.w, li.h))
+}
+
+// cell returns the cell index of a tile position within the layer bounds.
+func (li *LayerInfo) cell(x, y int) int {
+	return (y-li.y0)*li.w + (x - li.x0)
+}
+
 func extractLayerOffsets(layer *tmx.Layer) (float64, float64) {
 	if layer == nil {
 		return 0.0, 0.0
@@ -60,58 +131,21 @@ func extractLayerOffsets(layer *tmx.Layer) (float64, float64) {
 	return offX, offY
 }
 
-func extractLayerColor(layer *tmx.Layer) (pixel.RGBA, error) {
-	if layer == nil {
-		return pixel.Alpha(1.0), errors.New("nil layer passed")
-	}
+// extractLayerColor returns the color mask of the layer, its tint color
+// multiplied with its opacity.
+func extractLayerColor(layer *tmx.Layer) pixel.RGBA {
 	opacity := 1.0
 	if layer.Opacity != nil {
 		opacity = *layer.Opacity
 	}
-	if layer.Visible != nil {
-		if *layer.Visible == 0 {
-			opacity = 0
-		}
+	if layer.Visible != nil && *layer.Visible == 0 {
+		opacity = 0
 	}
 	rgba := pixel.Alpha(opacity)
-
-	if layer.Color != nil {
-		s := strings.Trim(*layer.Color, "# ")
-		var err error
-		i := 0
-		var a, r, b, g uint64
-		switch len(s) {
-		case 8:
-			a, err = strconv.ParseUint(s[:2], 16, 8)
-			if err != nil {
-				return rgba, err
-			}
-			i = 2
-			fallthrough
-		case 6:
-			r, err = strconv.ParseUint(s[i:i+2], 16, 8)
-			if err != nil {
-				return rgba, err
-			}
-			b, err = strconv.ParseUint(s[i+2:i+4], 16, 8)
-			if err != nil {
-				return rgba, err
-			}
-			g, err = strconv.ParseUint(s[i+4:i+6], 16, 8)
-			if err != nil {
-				return rgba, err
-			}
-		default:
-			return rgba, errors.Errorf("invalid color: %s", s)
-		}
-		rgba.Mul(pixel.RGBA{
-			R: float64(r) / 255.0,
-			G: float64(g) / 255.0,
-			B: float64(b) / 255.0,
-			A: float64(a) / 255.0,
-		})
+	if layer.TintColor != nil {
+		rgba = rgba.Mul(pixel.ToRGBA(*layer.TintColor))
 	}
-	return rgba, nil
+	return rgba
 }
 
 // TileRect returns the pixel.Rect of a TMX map tile in pixel world coordinates.
@@ -122,13 +156,31 @@ func (li *LayerInfo) TileRect(cell int) (pixel.Rect, error) {
 	tw := float64(li.mapData.TileWidth)
 	th := float64(li.mapData.TileHeight)
 	return li.TMXToPixelRect(
-		float64(cell%li.w)*tw,
-		float64(cell/li.w)*th,
+		float64(li.x0+cell%li.w)*tw,
+		float64(li.y0+cell/li.w)*th,
 		tw,
 		th,
 	), nil
 }
 
+// TileImageRect returns the pixel.Rect of a w x h tile image placed in a TMX
+// map tile in pixel world coordinates. As in Tiled, the image is anchored to
+// the bottom-left corner of the map tile and moved by the tileset offset
+// (offX, offY) given in TMX coordinates.
+func (li *LayerInfo) TileImageRect(cell int, w, h, offX, offY float64) (pixel.Rect, error) {
+	if cell > (li.w * li.h) {
+		return pixel.R(0, 0, 0, 0), errors.Errorf("cell out of range (%d > %d)", cell, li.w*li.h)
+	}
+	tw := float64(li.mapData.TileWidth)
+	th := float64(li.mapData.TileHeight)
+	return li.TMXToPixelRect(
+		float64(li.x0+cell%li.w)*tw+offX,
+		float64(li.y0+cell/li.w+1)*th-h+offY,
+		w,
+		h,
+	), nil
+}
+
 // TMXToPixelVec translates TMX x and y coordinates to a pixel.Vect in pixel
 // world coordinates.
 func (li *LayerInfo) TMXToPixelVec(x, y float64) pixel.Vec {
@@ -7,31 +7,86 @@ import (
 	"strings"
 
 	"github.com/elliotmr/tmx"
+	"github.com/elliotmr/tmx/tmxtext"
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/text"
 	"github.com/pkg/errors"
 	"golang.org/x/image/colornames"
-	"golang.org/x/image/font/basicfont"
 )
 
+// objectEntry is an object prepared for drawing. The batches are rebuilt
+// from the visible entries whenever they change.
+type objectEntry struct {
+	picture pixel.Picture
+	bounds  pixel.Rect
+	draw    func(t pixel.Target)
+}
+
 type objectGroupDrawer struct {
-	resources       *Resources
-	info            *LayerInfo
-	currentFirstGID uint64
-	batches         []*pixel.Batch
+	resources      *Resources
+	info           *LayerInfo
+	entries        []objectEntry
+	visible        []int // the entries the batches were built from
+	built          bool
+	currentPicture pixel.Picture
+	batches        []*pixel.Batch
 }
 
 func newObjectGroupDrawer(resources *Resources, info *LayerInfo) (*objectGroupDrawer, error) {
 	od := &objectGroupDrawer{
-		resources:       resources,
-		info:            info,
-		currentFirstGID: math.MaxUint64,
-		batches:         make([]*pixel.Batch, 0),
+		resources: resources,
+		info:      info,
+		batches:   make([]*pixel.Batch, 0),
 	}
 	return od, od.Update()
 }
 
+// add adds an object drawn by draw into a batch of pic. The bounds are
+// those of the local points transformed by m.
+func (ogd *objectGroupDrawer) add(pic pixel.Picture, m pixel.Matrix, local []pixel.Vec, draw func(t pixel.Target)) {
+	var bounds pixel.Rect
+	for i, v := range local {
+		p := m.Project(v)
+		if i == 0 {
+			bounds = pixel.R(p.X, p.Y, p.X, p.Y)
+			continue
+		}
+		bounds = pixel.R(
+			math.Min(bounds.Min.X, p.X), math.Min(bounds.Min.Y, p.Y),
+			math.Max(bounds.Max.X, p.X), math.Max(bounds.Max.Y, p.Y),
+		)
+	}
+	ogd.entries = append(ogd.entries, objectEntry{picture: pic, bounds: bounds, draw: draw})
+}
+
+// corners returns the corners of a w x h rectangle centered on the origin.
+func corners(w, h float64) []pixel.Vec {
+	return []pixel.Vec{pixel.V(-w/2, -h/2), pixel.V(w/2, -h/2), pixel.V(w/2, h/2), pixel.V(-w/2, h/2)}
+}
+
+// padded returns the points of a line and the corners of squares of the
+// line width around them.
+func padded(pts []pixel.Vec, width float64) []pixel.Vec {
+	var out []pixel.Vec
+	for _, p := range pts {
+		for _, c := range corners(width, width) {
+			out = append(out, p.Add(c))
+		}
+	}
+	return out
+}
+
+// batch returns the batch that objects using pic should be drawn to. A new
+// batch is started whenever the picture changes to preserve the draw order.
+func (ogd *objectGroupDrawer) batch(pic pixel.Picture) *pixel.Batch {
+	if len(ogd.batches) == 0 || ogd.currentPicture != pic {
+		ogd.batches = append(ogd.batches, pixel.NewBatch(&pixel.TrianglesData{}, pic))
+		ogd.currentPicture = pic
+	}
+	return ogd.batches[len(ogd.batches)-1]
+}
+
 func getPosition(object *tmx.Object, li *LayerInfo) pixel.Vec {
 	var v pixel.Vec
 	if object.Height != nil && object.Width != nil {
@@ -77,9 +132,17 @@ func flipDiagonal(around pixel.Vec, m pixel.Matrix) pixel.Matrix {
 	return pixel.Matrix{m[1], -m[0], m[3], -m[2], m[5] - around.Y + around.X, -m[4] + around.X + around.Y}
 }
 
-func (ogd *objectGroupDrawer) createMatrixTile(tile tmx.TileInstance, frame pixel.Rect, object *tmx.Object) pixel.Matrix {
-	// Get Initial Position
-	v := getPosition(object, ogd.info).Add(pixel.V(0.0, *object.Height))
+func (ogd *objectGroupDrawer) createMatrixTile(tile tmx.TileInstance, entry tileSetEntry, object *tmx.Object) pixel.Matrix {
+	frame := entry.frame
+	// Get Initial Position, the object position is the anchor of the tile
+	// and the tileset offset rotates together with the object
+	origin := ogd.info.TMXToPixelVec(object.X, object.Y)
+	ax, ay := entry.tileSet.ObjectAnchor(ogd.info.mapData.Orientation)
+	offX, offY := entry.offset()
+	v := origin.Add(pixel.V(
+		(0.5-ax)*(*object.Width)+offX,
+		(ay-0.5)*(*object.Height)-offY,
+	))
 	m := pixel.IM.Moved(v)
 
 	// Rotate 90 deg around center for diagonal flip
@@ -104,24 +167,65 @@ func (ogd *objectGroupDrawer) createMatrixTile(tile tmx.TileInstance, frame pixe
 
 	m = m.ScaledXY(v, pixel.V(xScale, yScale))
 
-	bottomLeft := v.Sub(pixel.V(*object.Width/2, *object.Height/2))
 	if object.Rotation != nil {
-		m = m.Rotated(bottomLeft, *object.Rotation*-math.Pi/180.0)
+		m = m.Rotated(origin, *object.Rotation*-math.Pi/180.0)
 	}
 	return m
 }
 
 func (ogd *objectGroupDrawer) createIMD(obj *tmx.Object) *imdraw.IMDraw {
 	imd := imdraw.New(nil)
-	imd.Color = pixel.Alpha(0.5).Mul(pixel.ToRGBA(colornames.White))
-	imd.SetMatrix(ogd.createMatrix(obj))
-	if ogd.currentFirstGID != 0 {
-		ogd.batches = append(ogd.batches, pixel.NewBatch(&pixel.TrianglesData{}, nil))
-		ogd.currentFirstGID = 0
+	c := pixel.ToRGBA(colornames.White)
+	if ogd.info.layer.Color != nil {
+		c = pixel.ToRGBA(*ogd.info.layer.Color)
 	}
+	imd.Color = pixel.Alpha(0.5).Mul(c).Mul(ogd.info.color)
+	imd.SetMatrix(ogd.createMatrix(obj))
 	return imd
 }
 
+// drawText lays out the text inside the object rectangle, the text is
+// rotated around the top-left corner of the object like in Tiled.
+func (ogd *objectGroupDrawer) drawText(obj *tmx.Object) error {
+	at, face, err := ogd.resources.atlas(obj.Text)
+	if err != nil {
+		return err
+	}
+	w, h := 0.0, 0.0
+	if obj.Width != nil && obj.Height != nil {
+		w, h = *obj.Width, *obj.Height
+	}
+	m := pixel.IM
+	if obj.Rotation != nil {
+		m = m.Rotated(pixel.ZV, *obj.Rotation*-math.Pi/180.0)
+	}
+	m = m.Moved(ogd.info.TMXToPixelVec(obj.X, obj.Y))
+	c := pixel.ToRGBA(obj.Text.TextColor()).Mul(ogd.info.color)
+
+	lines := tmxtext.Layout(obj.Text, face, w, h)
+	txt := text.New(pixel.ZV, at)
+	txt.Color = c
+	imd := imdraw.New(nil)
+	imd.Color = c
+	imd.SetMatrix(m)
+	for _, line := range lines {
+		txt.Dot = pixel.V(line.X, -line.Y)
+		txt.WriteString(line.Text)
+		for _, d := range tmxtext.Decorations(obj.Text, face, line) {
+			imd.Push(pixel.V(d.X, -d.Y), pixel.V(d.X+d.W, -d.Y-d.H))
+			imd.Rectangle(0)
+		}
+	}
+	local := []pixel.Vec{pixel.V(0, 0), pixel.V(w, 0), pixel.V(w, -h), pixel.V(0, -h)}
+	for _, line := range lines {
+		// texts without a size are as large as their lines
+		local = append(local, pixel.V(line.X+line.Width, -line.Y-float64(face.Metrics().Descent)/64))
+	}
+	ogd.add(at.Picture(), m, local, func(t pixel.Target) { txt.Draw(t, m) })
+	ogd.add(nil, m, local, func(t pixel.Target) { imd.Draw(t) })
+	return nil
+}
+
 func (ogd *objectGroupDrawer) Type() int {
 	return ObjectGroupDrawer
 }
@@ -132,7 +236,8 @@ func (ogd *objectGroupDrawer) Info() *LayerInfo {
 
 func (ogd *objectGroupDrawer) Update() error {
 	// TODO: Template support
-	ogd.batches = ogd.batches[:0] // TODO: Persist batches?
+	ogd.entries = ogd.entries[:0]
+	ogd.built = false
 	for _, obj := range ogd.info.layer.Objects {
 		if obj.Visible != nil && *obj.Visible == 0 {
 			continue // skip invisible objects
@@ -146,16 +251,12 @@ func (ogd *objectGroupDrawer) Update() error {
 				continue
 			}
 			pic := ogd.resources.images[entry.source]
-			if ogd.currentFirstGID != uint64(entry.firstGID) {
-				ogd.batches = append(ogd.batches, pixel.NewBatch(&pixel.TrianglesData{}, pic))
-				ogd.currentFirstGID = uint64(entry.firstGID)
-			}
 			sprite := pixel.NewSprite(pic, entry.frame)
 			if obj.Width == nil || obj.Height == nil {
 				return errors.New("tile object without width or height set")
 			}
-			m := ogd.createMatrixTile(tile, entry.frame, obj)
-			sprite.Draw(ogd.batches[len(ogd.batches)-1], m)
+			m := ogd.createMatrixTile(tile, entry, obj)
+			ogd.add(pic, m, corners(entry.frame.W(), entry.frame.H()), func(t pixel.Target) { sprite.Draw(t, m) })
 		case obj.Ellipse != nil:
 			imd := ogd.createIMD(obj)
 			if obj.Width == nil || obj.Height == nil {
@@ -163,7 +264,7 @@ func (ogd *objectGroupDrawer) Update() error {
 			}
 			imd.Push(pixel.V(0, 0))
 			imd.Ellipse(pixel.V(*obj.Width/2, *obj.Height/2), 0)
-			imd.Draw(ogd.batches[len(ogd.batches)-1])
+			ogd.add(nil, ogd.createMatrix(obj), corners(*obj.Width, *obj.Height), func(t pixel.Target) { imd.Draw(t) })
 		case obj.Point != nil:
 			// TODO
 		case obj.Polygon != nil:
@@ -175,10 +276,7 @@ func (ogd *objectGroupDrawer) Update() error {
 			imd.Push(l...)
 			imd.Polygon(0)
 			// BUG(elliotmr): something strange is happening with polygon rendering.
-			if ogd.currentFirstGID != 0 {
-				ogd.batches = append(ogd.batches, pixel.NewBatch(&pixel.TrianglesData{}, nil))
-			}
-			imd.Draw(ogd.batches[len(ogd.batches)-1])
+			ogd.add(nil, ogd.createMatrix(obj), l, func(t pixel.Target) { imd.Draw(t) })
 		case obj.Polyline != nil:
 			imd := ogd.createIMD(obj)
 			l, err := getLine(obj.Polyline.Points, ogd.info)
@@ -188,17 +286,12 @@ func (ogd *objectGroupDrawer) Update() error {
 			imd.EndShape = imdraw.RoundEndShape
 			imd.Push(l...)
 			imd.Line(10)
-			imd.Draw(ogd.batches[len(ogd.batches)-1])
+			ogd.add(nil, ogd.createMatrix(obj), padded(l, 10), func(t pixel.Target) { imd.Draw(t) })
 		case obj.Text != nil:
-			// TODO: font, style handling
-			at := text.NewAtlas(basicfont.Face7x13, text.ASCII)
-			txt := text.New(ogd.info.TMXToPixelRect(obj.X, obj.Y, 0, *obj.Height).Center(), at)
-			fmt.Fprint(txt, obj.Text.Text)
-			if ogd.currentFirstGID != math.MaxUint32+1 {
-				ogd.batches = append(ogd.batches, pixel.NewBatch(&pixel.TrianglesData{}, at.Picture()))
-				ogd.currentFirstGID = math.MaxUint32 + 1
+			err := ogd.drawText(obj)
+			if err != nil {
+				return errors.Wrap(err, "invalid text")
 			}
-			txt.Draw(ogd.batches[len(ogd.batches)-1], pixel.IM)
 		default: // Box
 			imd := ogd.createIMD(obj)
 			if obj.Width == nil || obj.Height == nil {
@@ -206,14 +299,52 @@ func (ogd *objectGroupDrawer) Update() error {
 			}
 			imd.Push(pixel.V(-(*obj.Width/2), -(*obj.Height/2)), pixel.V(*obj.Width/2, *obj.Height/2))
 			imd.Rectangle(0)
-			imd.Draw(ogd.batches[len(ogd.batches)-1])
+			ogd.add(nil, ogd.createMatrix(obj), corners(*obj.Width, *obj.Height), func(t pixel.Target) { imd.Draw(t) })
 		}
 	}
 	return nil
 }
 
-func (ogd *objectGroupDrawer) Draw(t pixel.Target) {
+// build rebuilds the batches from the entries intersecting the visible
+// area, it does nothing if the visible entries did not change.
+func (ogd *objectGroupDrawer) build(visible pixel.Rect, cull bool) {
+	var indices []int
+	for i, e := range ogd.entries {
+		if !cull || e.bounds.Intersects(visible) {
+			indices = append(indices, i)
+		}
+	}
+	if ogd.built && equalIndices(indices, ogd.visible) {
+		return
+	}
+	ogd.visible = indices
+	ogd.built = true
+	ogd.batches = ogd.batches[:0] // TODO: Persist batches?
+	ogd.currentPicture = nil
+	for _, i := range indices {
+		e := ogd.entries[i]
+		e.draw(ogd.batch(e.picture))
+	}
+}
+
+func equalIndices(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Draw draws the objects intersecting the visible bounds of the view.
+func (ogd *objectGroupDrawer) Draw(t pixel.Target, view View) {
+	ogd.build(view.visible(ogd.info))
+	view.apply(t, ogd.info)
 	for _, batch := range ogd.batches {
 		batch.Draw(t)
 	}
+	view.reset(t)
 }
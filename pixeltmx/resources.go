@@ -1,15 +1,16 @@
 package pixeltmx
 
 import (
-	"image"
-	"os"
-
+	"context"
 	_ "image/png" // This is required for the parsing png resource files
+	"unicode"
 
 	"github.com/elliotmr/tmx"
+	"github.com/elliotmr/tmx/tmxtext"
 	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/text"
 	"github.com/pkg/errors"
-	"path/filepath"
+	"golang.org/x/image/font"
 )
 
 type tileSetEntry struct {
@@ -17,53 +18,113 @@ type tileSetEntry struct {
 	frame    pixel.Rect
 	firstGID uint32
 	source   string
+	tileSet  *tmx.TileSet
+}
+
+// offset returns the tileset drawing offset in TMX coordinates.
+func (tse tileSetEntry) offset() (float64, float64) {
+	if tse.tileSet == nil || tse.tileSet.Offset == nil {
+		return 0.0, 0.0
+	}
+	return float64(tse.tileSet.Offset.X), float64(tse.tileSet.Offset.Y)
+}
+
+// size returns the size of the drawn tile, taking the diagonal flip into
+// account.
+func (tse tileSetEntry) size(tile tmx.TileInstance) (float64, float64) {
+	if tile.FlippedDiagonally() {
+		return tse.frame.H(), tse.frame.W()
+	}
+	return tse.frame.W(), tse.frame.H()
 }
 
 // Resources holds all the raw images and miscellaneous files required for
 // rendering a TMX map. This includes tilesets and tileset pictures, raw
 // images, object templates, etc.
 type Resources struct {
-	// TODO: add text atlas and template maps
+	// TODO: add template maps
+	Fonts       *tmxtext.FontRegistry // The fonts text objects are drawn with, register fonts before creating the drawers.
+	ChunkSize   int                   // The size in tiles of the chunks tile layers are built in, it is applied when a drawer is updated.
+	ChunkBudget int                   // The memory in bytes the triangles of the built chunks of all layers may use.
+
 	path    string
 	entries map[uint32]tileSetEntry
 	images  map[string]pixel.Picture
+	atlases map[font.Face]*text.Atlas
+	chunks  *chunkCache
 }
 
-func (r *Resources) loadImage(source string) (string, error) {
-	if filepath.IsAbs(source) {
-		source = filepath.Clean(source)
-	} else {
-		source = filepath.Join(r.path, source)
-	}
-	imageFile, err := os.Open(source)
+// atlas returns the glyph atlas of the font of a text object.
+func (r *Resources) atlas(t *tmx.Text) (*text.Atlas, font.Face, error) {
+	face, err := r.Fonts.Face(t.Font())
 	if err != nil {
-		return "", errors.Wrap(err, "unable to open tileset image")
+		return nil, nil, err
 	}
-	defer imageFile.Close()
-	tilesetImg, _, err := image.Decode(imageFile)
-	if err != nil {
-		return "", errors.Wrap(err, "unable to decode tileset image")
+	at, exists := r.atlases[face]
+	if !exists {
+		at = text.NewAtlas(face, text.ASCII, text.RangeTable(unicode.Latin))
+		r.atlases[face] = at
 	}
-	pic := pixel.PictureDataFromImage(tilesetImg)
-	r.images[source] = pic
-	return source, nil
+	return at, face, nil
 }
 
-func (r *Resources) loadLayer(layer *tmx.Layer) error {
-	// Load Images
-	if layer.Image != nil {
-		_, err := r.loadImage(layer.Image.Source)
-		if err != nil {
-			return err
+// LoadOptions configures LoadResourcesContext.
+type LoadOptions struct {
+	Workers  int                   // The maximum number of images decoded in parallel. Defaults to runtime.NumCPU().
+	Progress func(done, total int) // Called on the calling goroutine after each image has been loaded (optional).
+}
+
+func (r *Resources) loadTileSet(set *tmx.TileSet) error {
+	if set.Image == nil {
+		return r.loadImageCollection(set)
+	}
+	source := set.Image.Key(r.path)
+	bounds := r.images[source].Bounds()
+	// tmx convention right -> down (origin top left), pixel convetion right -> up (origin bottom left)
+	// this means we have to flip the row index
+	rows := set.TileCount / set.Columns
+
+	for id := uint32(0); id < set.TileCount; id++ {
+		row := rows - id/set.Columns - 1
+		col := id % set.Columns
+		minX := float64(set.Margin + col*(set.TileWidth+set.Spacing))
+		minY := float64(set.Margin + row*(set.TileHeight+set.Spacing))
+		maxX := float64(set.Margin + col*(set.TileWidth+set.Spacing) + set.TileWidth)
+		maxY := float64(set.Margin + row*(set.TileHeight+set.Spacing) + set.TileHeight)
+		if minX < bounds.Min.X || minY < bounds.Min.Y || maxX > bounds.Max.X || maxY > bounds.Max.Y {
+			return errors.Errorf("tile %d bounds outside of texture bounds (%f, %f, %f, %f)", id, minX, minY, maxX, maxY)
+		}
+		frame := pixel.R(minX, minY, maxX, maxY)
+		r.entries[id+set.FirstGID] = tileSetEntry{
+			frame:    frame,
+			data:     createTriangleData(frame),
+			firstGID: set.FirstGID,
+			source:   source,
+			tileSet:  set,
 		}
 	}
-	// TODO: Load Templates
+	return nil
+}
 
-	// walk the children recursively.
-	for _, child := range layer.Layers {
-		err := r.loadLayer(child)
-		if err != nil {
-			return err
+// loadImageCollection creates the entries for a tileset where every tile
+// has its own image. The frame of each tile covers its entire image.
+func (r *Resources) loadImageCollection(set *tmx.TileSet) error {
+	for _, tile := range set.Tiles {
+		if tile.Image == nil {
+			continue
+		}
+		source := tile.Image.Key(r.path)
+		pic, exists := r.images[source]
+		if !exists {
+			return errors.Errorf("image for tile %d not loaded", tile.ID)
+		}
+		frame := pic.Bounds()
+		r.entries[tile.ID+set.FirstGID] = tileSetEntry{
+			frame:    frame,
+			data:     createTriangleData(frame),
+			firstGID: set.FirstGID,
+			source:   source,
+			tileSet:  set,
 		}
 	}
 	return nil
@@ -73,55 +134,55 @@ func (r *Resources) loadLayer(layer *tmx.Layer) error {
 // the resources are located somewhere other than the current working directory, the
 // location should be supplied in the path string.
 func LoadResources(mapData *tmx.Map, path string) (*Resources, error) {
+	return LoadResourcesContext(context.Background(), mapData, path, nil)
+}
+
+// LoadResourcesContext works like LoadResources, but decodes the images in
+// parallel. Loading stops early when the context is cancelled, otherwise all
+// failures are collected and returned together as a tmx.ErrorList.
+func LoadResourcesContext(ctx context.Context, mapData *tmx.Map, path string, opts *LoadOptions) (*Resources, error) {
 	// TODO: figure out how to abstract the file system (maybe use Afero?)
 	if path == "" {
 		path = "."
 	}
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
 	r := &Resources{
-		path:    path,
-		entries: make(map[uint32]tileSetEntry),
-		images:  make(map[string]pixel.Picture),
+		Fonts:       tmxtext.NewFontRegistry(),
+		ChunkSize:   DefaultChunkSize,
+		ChunkBudget: DefaultChunkBudget,
+		path:        path,
+		entries:     make(map[uint32]tileSetEntry),
+		images:      make(map[string]pixel.Picture),
+		atlases:     make(map[font.Face]*text.Atlas),
+		chunks:      newChunkCache(),
+	}
+	decoded, err := tmx.DecodeImages(ctx, path, mapData.Images(), &tmx.DecodeOptions{
+		Workers:  opts.Workers,
+		Progress: opts.Progress,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load resources")
 	}
-	for _, set := range mapData.TileSets {
-		source, err := r.loadImage(set.Image.Source)
-		if err != nil {
-			return nil, err
-		}
-		bounds := r.images[source].Bounds()
-		// tmx convention right -> down (origin top left), pixel convetion right -> up (origin bottom left)
-		// this means we have to flip the row index
-		rows := set.TileCount / set.Columns
-
-		for id := uint32(0); id < set.TileCount; id++ {
-			row := rows - id/set.Columns - 1
-			col := id % set.Columns
-			minX := float64(set.Margin + col*(set.TileWidth+set.Spacing))
-			minY := float64(set.Margin + row*(set.TileHeight+set.Spacing))
-			maxX := float64(set.Margin + col*(set.TileWidth+set.Spacing) + set.TileWidth)
-			maxY := float64(set.Margin + row*(set.TileHeight+set.Spacing) + set.TileHeight)
-			if minX < bounds.Min.X || minY < bounds.Min.Y || maxX > bounds.Max.X || maxY > bounds.Max.Y {
-				return nil, errors.Errorf("tile %d bounds outside of texture bounds (%f, %f, %f, %f)", id, minX, minY, maxX, maxY)
-			}
-			frame := pixel.R(minX, minY, maxX, maxY)
-			r.entries[id+set.FirstGID] = tileSetEntry{
-				frame:    frame,
-				data:     createTriangleData(frame),
-				firstGID: set.FirstGID,
-				source:   source,
-			}
-		}
+	for key, img := range decoded {
+		r.images[key] = pixel.PictureDataFromImage(img)
 	}
 
-	for _, l := range mapData.Layers {
-		err := r.loadLayer(l)
+	var el tmx.ErrorList
+	for _, set := range mapData.TileSets {
+		err := r.loadTileSet(set)
 		if err != nil {
-			return nil, errors.Wrap(err, "unable to load resources")
+			el = append(el, errors.Wrapf(err, "invalid tileset '%s'", set.Name))
 		}
 	}
+	if err := el.Err(); err != nil {
+		return nil, errors.Wrap(err, "unable to load resources")
+	}
 	return r, nil
 }
 
-var diagonalFlipMatrix = pixel.Matrix{0, -1, 1, 0, 0, 0}
+var diagonalFlipMatrix = pixel.Matrix{0, -1, -1, 0, 0, 0}
 var horizontalFlipMatrix = pixel.Matrix{-1, 0, 0, 1, 0, 0}
 var verticalFlipMatrix = pixel.Matrix{1, 0, 0, -1, 0, 0}
 
@@ -153,8 +214,11 @@ func (r *Resources) fillTileAndMod(tile tmx.TileInstance, rect pixel.Rect, rbga
 		}
 	}
 
+	// scale the tile to the rect for tiles that are not drawn at their native size
+	w, h := r.entries[tile.GID()].size(tile)
+	scale := pixel.V(rect.W()/w, rect.H()/h)
 	for i := range *data {
-		(*data)[i].Position = (*data)[i].Position.Add(rect.Center())
+		(*data)[i].Position = (*data)[i].Position.ScaledXY(scale).Add(rect.Center())
 		(*data)[i].Color = rbga
 	}
 	t.Update(data)
@@ -0,0 +1,26 @@
+package pixeltmx
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlipMatrices(t *testing.T) {
+	// positions are relative to the center of the tile with y pointing up,
+	// the diagonal flip swaps the top-right and bottom-left corners
+	topLeft, topRight := pixel.V(-8, 4), pixel.V(8, 4)
+	bottomLeft := pixel.V(-8, -4)
+	assert.Equal(t, pixel.V(-4, 8), diagonalFlipMatrix.Project(topLeft))
+	assert.Equal(t, pixel.V(-4, -8), diagonalFlipMatrix.Project(topRight))
+	assert.Equal(t, pixel.V(4, 8), diagonalFlipMatrix.Project(bottomLeft))
+
+	// diagonal and horizontal flips are a clockwise rotation in Tiled, the
+	// top-left corner ends up at the top-right
+	cw := func(v pixel.Vec) pixel.Vec {
+		return horizontalFlipMatrix.Project(diagonalFlipMatrix.Project(v))
+	}
+	assert.Equal(t, pixel.V(4, 8), cw(topLeft))
+	assert.Equal(t, pixel.V(-4, 8), cw(bottomLeft))
+}
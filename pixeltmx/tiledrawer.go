@@ -1,6 +1,10 @@
 package pixeltmx
 
 import (
+	"image"
+	"math"
+
+	"github.com/elliotmr/tmx"
 	"github.com/faiface/pixel"
 	"github.com/pkg/errors"
 )
@@ -8,26 +12,17 @@ import (
 type tileLayerDrawer struct {
 	resources *Resources
 	info      *LayerInfo
-	drawers   map[uint32]*pixel.Drawer
+	tiles     []tmx.TileInstance // the tiles of the layer bounds by cell
+	marginMin pixel.Vec          // how far tile images reach below and left of their cell
+	marginMax pixel.Vec          // how far tile images reach above and right of their cell
+	chunkSize int
 }
 
 func newTileLayerDrawer(resources *Resources, info *LayerInfo) (*tileLayerDrawer, error) {
 	ld := &tileLayerDrawer{
 		resources: resources,
 		info:      info,
-		drawers:   make(map[uint32]*pixel.Drawer),
-	}
-
-	for _, entry := range resources.entries {
-		_, exists := ld.drawers[entry.firstGID]
-		if !exists {
-			ld.drawers[entry.firstGID] = &pixel.Drawer{
-				Triangles: &pixel.TrianglesData{},
-				Picture:   ld.resources.images[entry.source],
-			}
-		}
 	}
-
 	return ld, ld.Update()
 }
 
@@ -40,35 +35,146 @@ func (ld *tileLayerDrawer) Info() *LayerInfo {
 }
 
 func (ld *tileLayerDrawer) Update() error {
-	// TODO: draworder
-	iter, err := ld.info.layer.Data.Iter()
+	ld.resources.chunks.removeLayer(ld)
+	ld.chunkSize = ld.resources.ChunkSize
+	if ld.chunkSize <= 0 {
+		ld.chunkSize = DefaultChunkSize
+	}
+	ld.tiles = make([]tmx.TileInstance, ld.info.w*ld.info.h)
+	ld.marginMin, ld.marginMax = pixel.ZV, pixel.ZV
+	var tileErr error
+	err := ld.info.layer.Data.ForEach(ld.info.w, func(x, y int, tile tmx.TileInstance) {
+		if tileErr != nil {
+			return
+		}
+		tileErr = ld.place(ld.info.cell(x, y), tile)
+	})
+	if tileErr != nil {
+		return tileErr
+	}
+	return err
+}
+
+// place validates a tile and puts it into a cell, the margins are grown to
+// include its image.
+func (ld *tileLayerDrawer) place(cell int, tile tmx.TileInstance) error {
+	if tile.GID() != 0 {
+		tse, exists := ld.resources.entries[tile.GID()]
+		if !exists {
+			return errors.Errorf("tile with gid '%d' does not exist", tile.GID())
+		}
+		loc, _ := ld.tileRect(tile, tse, cell)
+		rect, _ := ld.info.TileRect(cell)
+		ld.marginMin = pixel.V(math.Min(ld.marginMin.X, loc.Min.X-rect.Min.X), math.Min(ld.marginMin.Y, loc.Min.Y-rect.Min.Y))
+		ld.marginMax = pixel.V(math.Max(ld.marginMax.X, loc.Max.X-rect.Max.X), math.Max(ld.marginMax.Y, loc.Max.Y-rect.Max.Y))
+	}
+	ld.tiles[cell] = tile
+	return nil
+}
+
+// SetTile changes the tile at position (x, y) in map tiles, both in the layer
+// data and in the drawer. Only the triangles of the chunk containing the
+// tile are built again, the position must be within the bounds of the layer.
+func (ld *tileLayerDrawer) SetTile(x, y int, tile tmx.TileInstance) error {
+	if !image.Pt(x, y).In(image.Rect(ld.info.x0, ld.info.y0, ld.info.x0+ld.info.w, ld.info.y0+ld.info.h)) {
+		return errors.Errorf("tile (%d, %d) outside of the layer bounds", x, y)
+	}
+	if _, exists := ld.resources.entries[tile.GID()]; tile.GID() != 0 && !exists {
+		return errors.Errorf("unable to set tile: tile with gid '%d' does not exist", tile.GID())
+	}
+	err := ld.info.layer.Data.SetTile(ld.info.w, x, y, tile)
 	if err != nil {
-		return errors.Wrap(err, "unable to load layer iterator")
+		return errors.Wrap(err, "unable to set tile")
 	}
+	_ = ld.place(ld.info.cell(x, y), tile)
+	n := ld.chunkSize
+	ld.resources.chunks.remove(chunkKey{layer: ld, x: floorDiv(x, n), y: floorDiv(y, n)})
+	return nil
+}
+
+// chunk returns the render chunk at (cx, cy), its triangles are built if it
+// is not cached.
+func (ld *tileLayerDrawer) chunk(cx, cy int) *renderChunk {
+	key := chunkKey{layer: ld, x: cx, y: cy}
+	if rc, exists := ld.resources.chunks.get(key); exists {
+		return rc
+	}
+	rc := ld.buildChunk(key)
+	ld.resources.chunks.put(rc, ld.resources.ChunkBudget)
+	return rc
+}
 
-	for gid, drawer := range ld.drawers {
-		i := 1
-		for iter.Next() {
-			tse := ld.resources.entries[iter.Get().GID()]
-			if tse.firstGID != gid {
+// buildChunk fills the triangles of a chunk, with one drawer per tileset
+// picture in the order the pictures first appear.
+func (ld *tileLayerDrawer) buildChunk(key chunkKey) *renderChunk {
+	n := ld.chunkSize
+	cells := image.Rect(
+		key.x*n-ld.info.x0, key.y*n-ld.info.y0,
+		(key.x+1)*n-ld.info.x0, (key.y+1)*n-ld.info.y0,
+	).Intersect(image.Rect(0, 0, ld.info.w, ld.info.h))
+	rc := &renderChunk{key: key}
+	drawers := make(map[string]*pixel.Drawer)
+	// TODO: draworder
+	for y := cells.Min.Y; y < cells.Max.Y; y++ {
+		for x := cells.Min.X; x < cells.Max.X; x++ {
+			cell := y*ld.info.w + x
+			tile := ld.tiles[cell]
+			if tile.GID() == 0 {
 				continue
 			}
-			if i*6 > drawer.Triangles.Len() {
-				drawer.Triangles.SetLen(i * 6)
+			tse := ld.resources.entries[tile.GID()]
+			drawer, exists := drawers[tse.source]
+			if !exists {
+				drawer = &pixel.Drawer{
+					Triangles: &pixel.TrianglesData{},
+					Picture:   ld.resources.images[tse.source],
+				}
+				drawers[tse.source] = drawer
+				rc.drawers = append(rc.drawers, drawer)
 			}
-			cellIndex := int(iter.GetIndex())
-			loc, _ := ld.info.TileRect(cellIndex)
-			triangleSlice := drawer.Triangles.Slice((i-1)*6, i*6)
-			ld.resources.fillTileAndMod(iter.Get(), loc, ld.info.color, triangleSlice)
-			i++
+			i := drawer.Triangles.Len()
+			drawer.Triangles.SetLen(i + 6)
+			loc, _ := ld.tileRect(tile, tse, cell)
+			ld.resources.fillTileAndMod(tile, loc, ld.info.color, drawer.Triangles.Slice(i, i+6))
+			rc.size += 6 * trianglesVertexSize
 		}
-		drawer.Triangles.SetLen(i * 6)
 	}
-	return errors.Wrap(iter.Error(), "unable to iterate through layer")
+	return rc
+}
+
+// tileRect returns the rect a tile is drawn to, taking the tileset offset
+// and render size into account.
+func (ld *tileLayerDrawer) tileRect(tile tmx.TileInstance, tse tileSetEntry, cell int) (pixel.Rect, error) {
+	w, h := tse.size(tile)
+	offX, offY := tse.offset()
+	w, h, padX, padY := tse.tileSet.RenderSize(
+		w, h,
+		float64(ld.info.mapData.TileWidth),
+		float64(ld.info.mapData.TileHeight),
+	)
+	return ld.info.TileImageRect(cell, w, h, offX+padX, offY-padY)
 }
 
-func (ld *tileLayerDrawer) Draw(t pixel.Target) {
-	for _, d := range ld.drawers {
-		d.Draw(t)
+// Draw draws the chunks intersecting the visible bounds of the view. Chunks
+// are aligned to multiples of the chunk size in map tiles, like the chunks
+// of infinite maps.
+func (ld *tileLayerDrawer) Draw(t pixel.Target, view View) {
+	cells := image.Rect(0, 0, ld.info.w, ld.info.h)
+	if r, cull := view.visible(ld.info); cull {
+		cells = ld.info.cellRange(r, ld.marginMin, ld.marginMax)
+	}
+	if cells.Empty() {
+		return
+	}
+	n := ld.chunkSize
+	cells = cells.Add(image.Pt(ld.info.x0, ld.info.y0))
+	view.apply(t, ld.info)
+	for cy := floorDiv(cells.Min.Y, n); cy*n < cells.Max.Y; cy++ {
+		for cx := floorDiv(cells.Min.X, n); cx*n < cells.Max.X; cx++ {
+			for _, d := range ld.chunk(cx, cy).drawers {
+				d.Draw(t)
+			}
+		}
 	}
+	view.reset(t)
 }
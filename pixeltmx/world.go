@@ -0,0 +1,206 @@
+package pixeltmx
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/elliotmr/tmx"
+	"github.com/faiface/pixel"
+	"github.com/pkg/errors"
+)
+
+// WorldDrawer draws the maps of a tmx.World around the camera. Only the maps
+// within Radius of the camera are kept loaded, each with its own Resources
+// and Drawer. Maps coming into range are loaded together with their images
+// in the background, maps going out of range are unloaded.
+//
+// As with single maps, the world is drawn in pixel world coordinates: the x
+// axis is the same as in the world file and the y axis points up, so the
+// world point (x, y) of the world file is at pixel.V(x, -y).
+type WorldDrawer struct {
+	Radius    float64            // The distance in world pixels from the camera within which maps are loaded.
+	Configure func(r *Resources) // Called on new resources before the drawer is created, e.g. to register fonts (optional).
+
+	world   *tmx.World
+	active  map[*tmx.WorldMap]*worldEntry
+	loading map[*tmx.WorldMap]bool
+	failed  map[*tmx.WorldMap]bool
+
+	mu   sync.Mutex
+	done []worldLoad
+}
+
+// worldEntry is a loaded map of the world.
+type worldEntry struct {
+	resources *Resources
+	drawer    Drawer
+	offset    pixel.Vec // the origin of the map in pixel world coordinates
+}
+
+// worldLoad is the result of loading a map in the background.
+type worldLoad struct {
+	wm        *tmx.WorldMap
+	mapData   *tmx.Map
+	resources *Resources
+	err       error
+}
+
+// NewWorldDrawer creates a drawer for the world, no maps are loaded until
+// the first call to Update.
+func NewWorldDrawer(world *tmx.World, radius float64, configure func(r *Resources)) *WorldDrawer {
+	return &WorldDrawer{
+		Radius:    radius,
+		Configure: configure,
+		world:     world,
+		active:    make(map[*tmx.WorldMap]*worldEntry),
+		loading:   make(map[*tmx.WorldMap]bool),
+		failed:    make(map[*tmx.WorldMap]bool),
+	}
+}
+
+// Update moves the camera to camera in pixel world coordinates. It starts
+// loading the maps that came into range, activates the maps that finished
+// loading and unloads the maps that went out of range. It must be called
+// between frames. Maps are found by their size in the world and are only
+// loaded in the background. Maps that failed to load are returned as a
+// tmx.ErrorList, they are not tried again until they have been out of range.
+func (wd *WorldDrawer) Update(camera pixel.Vec) error {
+	near := wd.world.MapsNear(camera.X, -camera.Y, wd.Radius)
+	wanted := make(map[*tmx.WorldMap]bool, len(near))
+	for _, wm := range near {
+		wanted[wm] = true
+		if wd.active[wm] == nil && !wd.loading[wm] && !wd.failed[wm] {
+			wd.loading[wm] = true
+			go wd.load(wm)
+		}
+	}
+
+	wd.mu.Lock()
+	done := wd.done
+	wd.done = nil
+	wd.mu.Unlock()
+	var el tmx.ErrorList
+	for _, l := range done {
+		delete(wd.loading, l.wm)
+		if !wanted[l.wm] {
+			l.wm.Unload()
+			continue
+		}
+		entry, err := wd.activate(l)
+		if err != nil {
+			l.wm.Unload()
+			wd.failed[l.wm] = true
+			el = append(el, errors.Wrapf(err, "unable to load world map '%s'", l.wm.FileName))
+			continue
+		}
+		wd.active[l.wm] = entry
+	}
+
+	for wm := range wd.active {
+		if !wanted[wm] {
+			delete(wd.active, wm)
+			wm.Unload()
+		}
+	}
+	for wm := range wd.failed {
+		if !wanted[wm] {
+			delete(wd.failed, wm)
+		}
+	}
+	return el.Err()
+}
+
+// load loads a map and its resources, it runs in the background.
+func (wd *WorldDrawer) load(wm *tmx.WorldMap) {
+	l := worldLoad{wm: wm}
+	l.mapData, l.err = wm.Map()
+	if l.err == nil {
+		l.resources, l.err = LoadResources(l.mapData, filepath.Dir(wm.Path()))
+	}
+	wd.mu.Lock()
+	wd.done = append(wd.done, l)
+	wd.mu.Unlock()
+}
+
+// activate creates the drawer of a loaded map.
+func (wd *WorldDrawer) activate(l worldLoad) (*worldEntry, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	if wd.Configure != nil {
+		wd.Configure(l.resources)
+	}
+	d, err := NewRootDrawer(l.resources, l.mapData)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create drawer")
+	}
+	// the origin of a map is its bottom-left corner
+	height := float64(l.mapData.Height * l.mapData.TileHeight)
+	return &worldEntry{
+		resources: l.resources,
+		drawer:    d,
+		offset:    pixel.V(float64(l.wm.X), -float64(l.wm.Y)-height),
+	}, nil
+}
+
+// Draw draws all active maps in the order of the world, each moved by its
+// position in the world. The view is given in pixel world coordinates of the
+// world, the matrix of the target is moved for each map, so maps are only
+// placed correctly on a pixel.BasicTarget.
+func (wd *WorldDrawer) Draw(target pixel.Target, view View) {
+	for _, wm := range wd.world.Maps {
+		entry := wd.active[wm]
+		if entry == nil {
+			continue
+		}
+		local := View{
+			Matrix: pixel.IM.Moved(entry.offset).Chained(view.matrix()),
+			Camera: view.Camera.Sub(entry.offset),
+		}
+		if view.Bounds != (pixel.Rect{}) {
+			local.Bounds = view.Bounds.Moved(entry.offset.Scaled(-1))
+		}
+		entry.drawer.Draw(target, local)
+	}
+	if bt, ok := target.(pixel.BasicTarget); ok {
+		bt.SetMatrix(view.matrix())
+	}
+}
+
+// Active returns the maps which are loaded and drawn, in the order of the
+// world.
+func (wd *WorldDrawer) Active() []*tmx.WorldMap {
+	var maps []*tmx.WorldMap
+	for _, wm := range wd.world.Maps {
+		if wd.active[wm] != nil {
+			maps = append(maps, wm)
+		}
+	}
+	return maps
+}
+
+// Drawer returns the root drawer of a map, or nil if it is not active.
+func (wd *WorldDrawer) Drawer(wm *tmx.WorldMap) Drawer {
+	if entry := wd.active[wm]; entry != nil {
+		return entry.drawer
+	}
+	return nil
+}
+
+// Resources returns the resources of a map, or nil if it is not active.
+func (wd *WorldDrawer) Resources(wm *tmx.WorldMap) *Resources {
+	if entry := wd.active[wm]; entry != nil {
+		return entry.resources
+	}
+	return nil
+}
+
+// Close unloads all active maps. Maps still loading in the background are
+// not waited for.
+func (wd *WorldDrawer) Close() {
+	for wm := range wd.active {
+		wm.Unload()
+	}
+	wd.active = make(map[*tmx.WorldMap]*worldEntry)
+	wd.failed = make(map[*tmx.WorldMap]bool)
+}
@@ -0,0 +1,55 @@
+package tmx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// emptyElement matches elements without content, which are written as
+// self-closing tags like Tiled does. Attribute values can not contain '<'
+// or '>' as the encoder escapes them.
+var emptyElement = regexp.MustCompile(`<([\w]+)([^<>]*)></[\w]+>`)
+
+// writeXML writes v as an XML document indented like the files of Tiled.
+func writeXML(w io.Writer, v interface{}) error {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", " ")
+	err := enc.Encode(v)
+	if err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = w.Write(emptyElement.ReplaceAll(buf.Bytes(), []byte("<$1$2/>")))
+	return err
+}
+
+// Write writes the map as a TMX file. Elements are written in the order
+// they were loaded in, and tile data keeps its encoding and compression
+// unless it has been changed. External tilesets are only written as
+// references, their TSX files are written by SaveTileSets.
+func (m *Map) Write(w io.Writer) error {
+	return errors.Wrap(writeXML(w, m), "unable to write map")
+}
+
+// Save writes the map to the TMX file at path, together with the TSX files
+// of its external tilesets that have been changed.
+func (m *Map) Save(path string) error {
+	var buf bytes.Buffer
+	err := m.Write(&buf)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, buf.Bytes(), 0644)
+	if err != nil {
+		return errors.Wrap(err, "unable to save map")
+	}
+	return m.SaveTileSets(filepath.Dir(path))
+}
@@ -0,0 +1,94 @@
+package tmx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// xmlElement is a start element with its attributes as a set.
+type xmlElement struct {
+	name  string
+	attrs map[string]string
+}
+
+func xmlElements(t *testing.T, data []byte) []xmlElement {
+	var elements []xmlElement
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return elements
+		}
+		require.NoError(t, err)
+		if start, ok := tok.(xml.StartElement); ok {
+			el := xmlElement{name: start.Name.Local, attrs: make(map[string]string)}
+			for _, attr := range start.Attr {
+				el.attrs[attr.Name.Local] = attr.Value
+			}
+			elements = append(elements, el)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("resources/*.tmx")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			original, err := ioutil.ReadFile(file)
+			require.NoError(t, err)
+			m, err := LoadReader(bytes.NewReader(original), file)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, m.Write(&buf))
+			out := buf.Bytes()
+			assert.True(t, bytes.HasPrefix(out, []byte(xml.Header+"<map ")))
+			assert.Equal(t, xmlElements(t, original), xmlElements(t, out))
+
+			// writing the loaded output again gives the same file
+			m2, err := LoadReader(bytes.NewReader(out), file)
+			require.NoError(t, err)
+			buf.Reset()
+			require.NoError(t, m2.Write(&buf))
+			assert.Equal(t, string(out), buf.String())
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tmx-save")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fp, err := os.Open("resources/cave.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+	m.TileSets[0].Name = "changed"
+	require.NoError(t, m.Layers[0].Data.SetTile(int(m.Width), 1, 2, 5))
+	path := filepath.Join(dir, "cave.tmx")
+	require.NoError(t, m.Save(path))
+
+	fp2, err := os.Open(path)
+	require.NoError(t, err)
+	defer fp2.Close()
+	saved, err := Load(fp2)
+	require.NoError(t, err)
+	assert.Equal(t, "cave.tsx", saved.TileSets[0].Source)
+	assert.Equal(t, "changed", saved.TileSets[0].Name)
+	assert.Equal(t, *m.Layers[0].Data.Encoding, *saved.Layers[0].Data.Encoding)
+	tiles, err := saved.Layers[0].Data.Tiles()
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, tiles[2*int(m.Width)+1])
+}
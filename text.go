@@ -0,0 +1,33 @@
+package tmx
+
+// Font describes the font of a text object with the TMX defaults applied.
+type Font struct {
+	Family  string
+	Size    int
+	Bold    bool
+	Italic  bool
+	Kerning bool
+}
+
+// Font returns the font of the text.
+func (t *Text) Font() Font {
+	f := Font{Family: "sans-serif", Size: 16, Kerning: true}
+	if t.FontFamily != nil {
+		f.Family = *t.FontFamily
+	}
+	if t.PixelSize != nil {
+		f.Size = *t.PixelSize
+	}
+	f.Bold = t.Bold != nil && *t.Bold == 1
+	f.Italic = t.Italic != nil && *t.Italic == 1
+	f.Kerning = t.Kerning == nil || *t.Kerning == 1
+	return f
+}
+
+// TextColor returns the color of the text, which defaults to black.
+func (t *Text) TextColor() Color {
+	if t.Color == nil {
+		return Color{A: 0xFF}
+	}
+	return *t.Color
+}
@@ -0,0 +1,25 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextFont(t *testing.T) {
+	obj := &Object{}
+	require.NoError(t, xml.Unmarshal([]byte(
+		`<object x="0" y="0"><text fontfamily="serif" pixelsize="20" italic="1" kerning="0">Fish &amp; Chips</text></object>`,
+	), obj))
+	assert.Equal(t, "Fish & Chips", obj.Text.Text)
+	assert.Equal(t, Font{Family: "serif", Size: 20, Italic: true}, obj.Text.Font())
+	assert.Equal(t, Color{A: 0xFF}, obj.Text.TextColor())
+
+	out, err := xml.Marshal(obj)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Fish &amp; Chips</text>")
+
+	assert.Equal(t, Font{Family: "sans-serif", Size: 16, Kerning: true}, (&Text{}).Font())
+}
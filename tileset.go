@@ -0,0 +1,57 @@
+package tmx
+
+import "math"
+
+// ObjectAnchor returns the point of a tile object image that is placed at
+// the object position, as fractions of the image size (0, 0 is the top-left
+// corner, 1, 1 the bottom-right corner). Unspecified alignments depend on the
+// map orientation like in Tiled.
+func (ts *TileSet) ObjectAnchor(orientation string) (float64, float64) {
+	alignment := "unspecified"
+	if ts.ObjectAlignment != nil {
+		alignment = *ts.ObjectAlignment
+	}
+	if alignment == "unspecified" {
+		alignment = "bottomleft"
+		if orientation == "isometric" {
+			alignment = "bottom"
+		}
+	}
+	switch alignment {
+	case "topleft":
+		return 0.0, 0.0
+	case "top":
+		return 0.5, 0.0
+	case "topright":
+		return 1.0, 0.0
+	case "left":
+		return 0.0, 0.5
+	case "center":
+		return 0.5, 0.5
+	case "right":
+		return 1.0, 0.5
+	case "bottom":
+		return 0.5, 1.0
+	case "bottomright":
+		return 1.0, 1.0
+	default: // bottomleft
+		return 0.0, 1.0
+	}
+}
+
+// RenderSize returns the size a w x h tile of this tileset is drawn with on
+// a tile layer of a map with the given tile size. It honours the
+// tilerendersize and fillmode attributes. The returned padding is the offset
+// required to center the tile in the grid cell when the aspect ratio is
+// preserved.
+func (ts *TileSet) RenderSize(w, h, tileW, tileH float64) (rw, rh, padX, padY float64) {
+	if ts.TileRenderSize == nil || *ts.TileRenderSize != "grid" || w == 0 || h == 0 {
+		return w, h, 0.0, 0.0
+	}
+	if ts.FillMode == nil || *ts.FillMode != "preserve-aspect-fit" {
+		return tileW, tileH, 0.0, 0.0
+	}
+	scale := math.Min(tileW/w, tileH/h)
+	rw, rh = w*scale, h*scale
+	return rw, rh, (tileW - rw) / 2, (tileH - rh) / 2
+}
@@ -11,43 +11,73 @@ import (
 
 // Map Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#map
 type Map struct {
-	Version         string  `xml:"version,attr"`                   // The TMX format version. Was “1.0” so far, and will be incremented to match minor Tiled releases.
-	TiledVersion    string  `xml:"tiledversion,attr"`              // The Tiled version used to save the file (since Tiled 1.0.1). May be a date (for snapshot builds).
-	Orientation     string  `xml:"orientation,attr"`               // Map orientation. Tiled supports “orthogonal”, “isometric”, “staggered” and “hexagonal” (since 0.11).
-	RenderOrder     *string `xml:"renderorder,attr,omitempty"`     // The order in which tiles on tile layers are rendered. Valid values are right-down (the default), right-up, left-down and left-up. In all cases, the map is drawn row-by-row. (only supported for orthogonal maps at the moment)
-	Width           uint32  `xml:"width,attr"`                     // The map width in tiles.
-	Height          uint32  `xml:"height,attr"`                    // The map height in tiles.
-	TileWidth       uint32  `xml:"tilewidth,attr"`                 // The width of a tile.
-	TileHeight      uint32  `xml:"tileheight,attr"`                // The height of a tile.
-	HexSideLength   *uint32 `xml:"hexsidelength,attr,omitempty"`   // Only for hexagonal maps. Determines the width or height (depending on the staggered axis) of the tile’s edge, in pixels.
-	StaggerAxis     *string `xml:"staggeraxis,attr,omitempty"`     // For staggered and hexagonal maps, determines which axis (“x” or “y”) is staggered. (since 0.11)
-	StaggerIndex    *string `xml:"staggerindex,attr,omitempty"`    // For staggered and hexagonal maps, determines whether the “even” or “odd” indexes along the staggered axis are shifted. (since 0.11)
-	BackgroundColor *string `xml:"backgroundcolor,attr,omitempty"` // The background color of the map. (optional, may include alpha value since 0.15 in the form #AARRGGBB
-	NextObjectId    uint32  `xml:"nextobjectid,attr"`              // Stores the next available ID for new objects. This number is stored to prevent reuse of the same ID after objects have been removed. (since 0.11)
+	Version          string   `xml:"version,attr"`                    // The TMX format version. Was “1.0” so far, and will be incremented to match minor Tiled releases.
+	TiledVersion     string   `xml:"tiledversion,attr"`               // The Tiled version used to save the file (since Tiled 1.0.1). May be a date (for snapshot builds).
+	Class            *string  `xml:"class,attr,omitempty"`            // The class of this map (since 1.9, defaults to “”).
+	Orientation      string   `xml:"orientation,attr"`                // Map orientation. Tiled supports “orthogonal”, “isometric”, “staggered” and “hexagonal” (since 0.11).
+	RenderOrder      *string  `xml:"renderorder,attr,omitempty"`      // The order in which tiles on tile layers are rendered. Valid values are right-down (the default), right-up, left-down and left-up. In all cases, the map is drawn row-by-row. (only supported for orthogonal maps at the moment)
+	CompressionLevel *int     `xml:"compressionlevel,attr,omitempty"` // The compression level to use for tile layer data (defaults to -1, which means to use the algorithm default). (since 1.3)
+	Width            uint32   `xml:"width,attr"`                      // The map width in tiles.
+	Height           uint32   `xml:"height,attr"`                     // The map height in tiles.
+	TileWidth        uint32   `xml:"tilewidth,attr"`                  // The width of a tile.
+	TileHeight       uint32   `xml:"tileheight,attr"`                 // The height of a tile.
+	HexSideLength    *uint32  `xml:"hexsidelength,attr,omitempty"`    // Only for hexagonal maps. Determines the width or height (depending on the staggered axis) of the tile’s edge, in pixels.
+	StaggerAxis      *string  `xml:"staggeraxis,attr,omitempty"`      // For staggered and hexagonal maps, determines which axis (“x” or “y”) is staggered. (since 0.11)
+	StaggerIndex     *string  `xml:"staggerindex,attr,omitempty"`     // For staggered and hexagonal maps, determines whether the “even” or “odd” indexes along the staggered axis are shifted. (since 0.11)
+	ParallaxOriginX  *float64 `xml:"parallaxoriginx,attr,omitempty"`  // X coordinate of the parallax origin in pixels (defaults to 0). (since 1.8)
+	ParallaxOriginY  *float64 `xml:"parallaxoriginy,attr,omitempty"`  // Y coordinate of the parallax origin in pixels (defaults to 0). (since 1.8)
+	BackgroundColor  *Color   `xml:"backgroundcolor,attr,omitempty"`  // The background color of the map. (optional, may include alpha value since 0.15 in the form #AARRGGBB
+	Infinite         *int     `xml:"infinite,attr,omitempty"`         // Whether this map is infinite (1) or not (0). An infinite map has no fixed size and stores its tile layer data in chunks. (since 1.1)
+	NextLayerID      uint32   `xml:"nextlayerid,attr,omitempty"`      // Stores the next available ID for new layers. This number is stored to prevent reuse of the same ID after layers have been removed. (since 1.2)
+	NextObjectId     uint32   `xml:"nextobjectid,attr"`               // Stores the next available ID for new objects. This number is stored to prevent reuse of the same ID after objects have been removed. (since 0.11)
+
+	EditorSettings *EditorSettings `xml:"editorsettings,omitempty"`
+	Properties     *Properties     `xml:"properties,omitempty"`
+	TileSets       []*TileSet      `xml:"tileset,omitempty"`
+	Layers         []*Layer        `xml:",any"`
+}
 
-	Properties *Properties `xml:"properties,omitempty"`
-	TileSets   []*TileSet  `xml:"tileset,omitempty"`
-	Layers     []*Layer    `xml:",any"`
+// EditorSettings Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#editorsettings
+// This element is only used by Tiled and stores settings of the editor.
+type EditorSettings struct {
+	ChunkSize *ChunkSize `xml:"chunksize,omitempty"`
+	Export    *Export    `xml:"export,omitempty"`
+}
+
+// ChunkSize Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#chunksize
+type ChunkSize struct {
+	Width  int `xml:"width,attr"`  // The width of chunks used for infinite maps (default to 16).
+	Height int `xml:"height,attr"` // The height of chunks used for infinite maps (default to 16).
+}
+
+// Export Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#export
+type Export struct {
+	Target string `xml:"target,attr"` // The last file this map was exported to.
+	Format string `xml:"format,attr"` // The short name of the last format this map was exported as.
 }
 
 // TileSet Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#tileset
 type TileSet struct {
-	FirstGID   uint32 `xml:"firstgid,attr"`   // The first global tile ID of this tileset (this global ID maps to the first tile in this tileset).
-	Source     string `xml:"source,attr"`     // If this tileset is stored in an external TSX (Tile Set XML) file, this attribute refers to that file. That TSX file has the same structure as the <tileset> element described here. (There is the firstgid attribute missing and this source attribute is also not there. These two attributes are kept in the TMX map, since they are map specific.)
-	Name       string `xml:"name,attr"`       // The name of this tileset.
-	TileWidth  uint32 `xml:"tilewidth,attr"`  // The (maximum) width of the tiles in this tileset.
-	TileHeight uint32 `xml:"tileheight,attr"` // The (maximum) height of the tiles in this tileset.
-	Spacing    uint32 `xml:"spacing,attr"`    // The spacing in pixels between the tiles in this tileset (applies to the tileset image).
-	Margin     uint32 `xml:"margin,attr"`     // The margin around the tiles in this tileset (applies to the tileset image).
-	TileCount  uint32 `xml:"tilecount,attr"`  // The number of tiles in this tileset (since 0.13)
-	Columns    uint32 `xml:"columns,attr"`    // The number of tile columns in the tileset. For image collection tilesets it is editable and is used when displaying the tileset. (since 0.15)
-
-	Offset       *TileOffset `xml:"tileoffset,omitempty"`
-	Properties   *Properties `xml:"properties,omitempty"`
-	Image        *Image      `xml:"image,omitempty"`
-	TerrainTypes []*Terrain  `xml:"terraintypes>terrain,omitempty"`
-	Tiles        []*Tile     `xml:"tile,omitempty"`
-	// TODO: Add Wangsets
+	FirstGID        uint32  `xml:"firstgid,attr"`                  // The first global tile ID of this tileset (this global ID maps to the first tile in this tileset).
+	Source          string  `xml:"source,attr,omitempty"`          // If this tileset is stored in an external TSX (Tile Set XML) file, this attribute refers to that file. That TSX file has the same structure as the <tileset> element described here. (There is the firstgid attribute missing and this source attribute is also not there. These two attributes are kept in the TMX map, since they are map specific.)
+	Name            string  `xml:"name,attr"`                      // The name of this tileset.
+	TileWidth       uint32  `xml:"tilewidth,attr"`                 // The (maximum) width of the tiles in this tileset.
+	TileHeight      uint32  `xml:"tileheight,attr"`                // The (maximum) height of the tiles in this tileset.
+	Spacing         uint32  `xml:"spacing,attr,omitempty"`         // The spacing in pixels between the tiles in this tileset (applies to the tileset image).
+	Margin          uint32  `xml:"margin,attr,omitempty"`          // The margin around the tiles in this tileset (applies to the tileset image).
+	TileCount       uint32  `xml:"tilecount,attr"`                 // The number of tiles in this tileset (since 0.13)
+	Columns         uint32  `xml:"columns,attr"`                   // The number of tile columns in the tileset. For image collection tilesets it is editable and is used when displaying the tileset. (since 0.15)
+	ObjectAlignment *string `xml:"objectalignment,attr,omitempty"` // Controls the alignment for tile objects. Valid values are unspecified, topleft, top, topright, left, center, right, bottomleft, bottom and bottomright. The default value is unspecified, for compatibility reasons. When unspecified, tile objects use bottomleft in orthogonal mode and bottom in isometric mode. (since 1.4)
+	TileRenderSize  *string `xml:"tilerendersize,attr,omitempty"`  // The size to use when rendering tiles from this tileset on a tile layer. Valid values are tile (the default) and grid. When set to grid, the tile is drawn at the tile grid size of the map. (since 1.9)
+	FillMode        *string `xml:"fillmode,attr,omitempty"`        // The fill mode to use when rendering tiles from this tileset. Valid values are stretch (the default) and preserve-aspect-fit. Only relevant when the tiles are not rendered at their native size. (since 1.9)
+
+	Offset       *TileOffset  `xml:"tileoffset,omitempty"`
+	Grid         *Grid        `xml:"grid,omitempty"`
+	Properties   *Properties  `xml:"properties,omitempty"`
+	Image        *Image       `xml:"image,omitempty"`
+	TerrainTypes TerrainTypes `xml:"terraintypes,omitempty"`
+	Tiles        []*Tile      `xml:"tile,omitempty"`
+	WangSets     *WangSets    `xml:"wangsets,omitempty"`
 }
 
 // TileOffset Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#tileoffset
@@ -56,6 +86,15 @@ type TileOffset struct {
 	Y int32 `xml:"y,attr"` // Vertical offset in pixels (positive is down)
 }
 
+// Grid Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#grid
+// This element is only used in case of isometric orientation, and determines
+// how tile overlays for terrain and collision information are rendered.
+type Grid struct {
+	Orientation string `xml:"orientation,attr"` // Orientation of the grid for the tiles in this tileset (orthogonal or isometric, defaults to orthogonal)
+	Width       uint32 `xml:"width,attr"`       // Width of a grid cell
+	Height      uint32 `xml:"height,attr"`      // Height of a grid cell
+}
+
 // Terrain Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#terrain
 type Terrain struct {
 	Name string `xml:"name,attr"` // The name of the terrain type.
@@ -77,6 +116,45 @@ type Tile struct {
 	Animation   []*Frame    `xml:"animation,omitempty"`
 }
 
+// WangSets Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#wangsets
+type WangSets struct {
+	WangSets []*WangSet `xml:"wangset"`
+}
+
+// WangSet Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#wangset
+type WangSet struct {
+	Name  string  `xml:"name,attr"`            // The name of the Wang set.
+	Class *string `xml:"class,attr,omitempty"` // The class of the Wang set (since 1.9, defaults to “”).
+	Type  *string `xml:"type,attr,omitempty"`  // The type of the Wang set, corner, edge or mixed (since 1.5).
+	Tile  int     `xml:"tile,attr"`            // The tile ID of the tile representing this Wang set, or -1 for none.
+
+	Properties  *Properties  `xml:"properties,omitempty"`
+	CornerColor []*WangColor `xml:"wangcornercolor,omitempty"` // Corner colors of Wang sets saved before Tiled 1.5.
+	EdgeColor   []*WangColor `xml:"wangedgecolor,omitempty"`   // Edge colors of Wang sets saved before Tiled 1.5.
+	Colors      []*WangColor `xml:"wangcolor,omitempty"`
+	Tiles       []*WangTile  `xml:"wangtile,omitempty"`
+}
+
+// WangColor Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#wangcolor
+type WangColor struct {
+	Name        string  `xml:"name,attr"`            // The name of this color.
+	Class       *string `xml:"class,attr,omitempty"` // The class of this color (since 1.9, defaults to “”).
+	Color       Color   `xml:"color,attr"`           // The color in #RRGGBB format (example: #c17d11).
+	Tile        int     `xml:"tile,attr"`            // The tile ID of the tile representing this color, or -1 for none.
+	Probability float64 `xml:"probability,attr"`     // The relative probability that this color is chosen over others in case of multiple options.
+
+	Properties *Properties `xml:"properties,omitempty"`
+}
+
+// WangTile Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#wangtile
+type WangTile struct {
+	TileID uint32 `xml:"tileid,attr"`          // The tile ID.
+	WangID string `xml:"wangid,attr"`          // The Wang ID, a comma-separated list of the Wang color index of the 8 edges and corners of the tile (a 32-bit unsigned hex number before Tiled 1.5).
+	HFlip  *int   `xml:"hflip,attr,omitempty"` // Whether the tile is flipped horizontally (removed in Tiled 1.5).
+	VFlip  *int   `xml:"vflip,attr,omitempty"` // Whether the tile is flipped vertically (removed in Tiled 1.5).
+	DFlip  *int   `xml:"dflip,attr,omitempty"` // Whether the tile is flipped on its diagonal (removed in Tiled 1.5).
+}
+
 // Frame Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#frame
 type Frame struct {
 	TileID   uint32  `xml:"tileid,attr"`   // The local ID of a tile within the parent <tileset>.
@@ -92,14 +170,22 @@ type Frame struct {
 type Layer struct {
 	XMLName xml.Name
 
+	ID        uint32   `xml:"id,attr,omitempty"`        // Unique ID of the layer. Each layer that is added to a map gets a unique id. Even if a layer is deleted, no layer ever gets the same ID. (since 1.2)
 	Name      string   `xml:"name,attr"`                // The name of the layer.
+	Class     *string  `xml:"class,attr,omitempty"`     // The class of the layer (since 1.9, defaults to “”).
 	Width     *uint32  `xml:"width,attr,omitempty"`     // The width of the layer in tiles. Always the same as the map width for fixed-size maps.
 	Height    *uint32  `xml:"height,attr,omitempty"`    // The height of the layer in tiles. Always the same as the map height for fixed-size maps.
-	Color     *string  `xml:"color,attr,omitempty"`     // The color used to display the objects in this group.
+	Color     *Color   `xml:"color,attr,omitempty"`     // The color used to display the objects in this group.
 	Opacity   *float64 `xml:"opacity,attr,omitempty"`   // The opacity of the layer as a value from 0 to 1. Defaults to 1.
 	Visible   *int     `xml:"visible,attr,omitempty"`   // Whether the layer is shown (1) or hidden (0). Defaults to 1.
+	Locked    *int     `xml:"locked,attr,omitempty"`    // Whether the layer is locked in the editor (1) or not (0). Defaults to 0. (since 1.2)
+	TintColor *Color   `xml:"tintcolor,attr,omitempty"` // A tint color that is multiplied with any tiles drawn by this layer in #AARRGGBB or #RRGGBB format (optional). (since 1.4)
 	OffsetX   *float64 `xml:"offsetx,attr,omitempty"`   // Rendering offset for this layer in pixels. Defaults to 0. (since 0.14)
 	OffsetY   *float64 `xml:"offsety,attr,omitempty"`   // Rendering offset for this layer in pixels. Defaults to 0. (since 0.14)
+	ParallaxX *float64 `xml:"parallaxx,attr,omitempty"` // Horizontal parallax factor for this layer. Defaults to 1. (since 1.5)
+	ParallaxY *float64 `xml:"parallaxy,attr,omitempty"` // Vertical parallax factor for this layer. Defaults to 1. (since 1.5)
+	RepeatX   *int     `xml:"repeatx,attr,omitempty"`   // Whether the image drawn by this image layer is repeated along the X axis (1) or not (0). Defaults to 0. (since 1.8)
+	RepeatY   *int     `xml:"repeaty,attr,omitempty"`   // Whether the image drawn by this image layer is repeated along the Y axis (1) or not (0). Defaults to 0. (since 1.8)
 	DrawOrder *string  `xml:"draworder,attr,omitempty"` // Whether the objects are drawn according to the order of appearance (“index”) or sorted by their y-coordinate (“topdown”). Defaults to “topdown”.
 
 	Properties *Properties `xml:"properties,omitempty"`
@@ -114,14 +200,18 @@ type Data struct {
 	Encoding    *string `xml:"encoding,attr,omitempty"`    // The encoding used to encode the tile layer data. When used, it can be “base64” and “csv” at the moment.
 	Compression *string `xml:"compression,attr,omitempty"` // The compression used to compress the tile layer data. Tiled supports “gzip” and “zlib”.
 
-	TileData []TileData `xml:"data,omitempty"`
+	TileData []TileData `xml:"tile,omitempty"`
 	Chunks   []Chunk    `xml:"chunk,omitempty"`
 	Data     []byte     `xml:",innerxml"`
+
+	tiles []TileInstance // Fixed-size tiles changed by SetTile and SetRegion, not yet encoded.
+	width int
 }
 
+// TileData is a single <tile> element of a tile layer without encoding.
 // This should probably not be used, rather use raw encoding
 type TileData struct {
-	GID uint32 `xml:"gid"`
+	GID uint32 `xml:"gid,attr,omitempty"`
 }
 
 // Chunk Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#chunk
@@ -131,14 +221,14 @@ type Chunk struct {
 	Width  int     `xml:"width,attr"`  // The width of the chunk in tiles.
 	Height int     `xml:"height,attr"` // The height of the chunk in tiles.
 
-	TileData []TileData `xml:"data,omitempty"`
+	TileData []TileData `xml:"tile,omitempty"`
 	Data     []byte     `xml:",innerxml"`
 }
 
 // Object Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#object
 type Object struct {
 	ID       uint32   `xml:"id,attr"`                 // Unique ID of the object. Each object that is placed on a map gets a unique id. Even if an object was deleted, no object gets the same ID. Can not be changed in Tiled. (since Tiled 0.11)
-	Name     string   `xml:"name,attr"`               // The name of the object. An arbitrary string.
+	Name     string   `xml:"name,attr,omitempty"`     // The name of the object. An arbitrary string.
 	Type     *string  `xml:"type,attr,omitempty"`     // The type of the object. An arbitrary string.
 	X        float64  `xml:"x,attr"`                  // The x coordinate of the object in pixels.
 	Y        float64  `xml:"y,attr"`                  // The y coordinate of the object in pixels.
@@ -148,12 +238,14 @@ type Object struct {
 	GID      *uint32  `xml:"gid,attr,omitempty"`      // A reference to a tile (optional).
 	Visible  *int     `xml:"visible,attr,omitempty"`  // Whether the object is shown (1) or hidden (0). Defaults to 1.
 	TID      *uint32  `xml:"tid,attr,omitempty"`      // A reference to a template (optional).
+	Template *string  `xml:"template,attr,omitempty"` // A reference to a template file (optional).
 
-	Ellipse  *Ellipse  `xml:"ellipse,omitempty"`
-	Point    *Point    `xml:"point,omitempty"`
-	Polygon  *Polygon  `xml:"polygon,omitempty"`
-	Polyline *Polyline `xml:"polyline,omitempty"`
-	Text     *Text     `xml:"text,omitempty"`
+	Properties *Properties `xml:"properties,omitempty"`
+	Ellipse    *Ellipse    `xml:"ellipse,omitempty"`
+	Point      *Point      `xml:"point,omitempty"`
+	Polygon    *Polygon    `xml:"polygon,omitempty"`
+	Polyline   *Polyline   `xml:"polyline,omitempty"`
+	Text       *Text       `xml:"text,omitempty"`
 }
 
 // Ellipse is used to mark an object as an ellipse. The existing x, y, width
@@ -185,7 +277,7 @@ type Text struct {
 	FontFamily *string `xml:"fontfamily,attr,omitempty"` // The font family used (default: “sans-serif”)
 	PixelSize  *int    `xml:"pixelsize,attr,omitempty"`  // The size of the font in pixels (not using points, because other sizes in the TMX format are also using pixels) (default: 16)
 	Wrap       *int    `xml:"wrap,attr,omitempty"`       // Whether word wrapping is enabled (1) or disabled (0). Defaults to 0.
-	Color      *string `xml:"color,attr,omitempty"`      // Color of the text in #AARRGGBB or #RRGGBB format (default: #000000)
+	Color      *Color  `xml:"color,attr,omitempty"`      // Color of the text in #AARRGGBB or #RRGGBB format (default: #000000)
 	Bold       *int    `xml:"bold,attr,omitempty"`       // Whether the font is bold (1) or not (0). Defaults to 0.
 	Italic     *int    `xml:"italic,attr,omitempty"`     // Whether the font is italic (1) or not (0). Defaults to 0.
 	Underline  *int    `xml:"underline,attr,omitempty"`  // Whether a line should be drawn below the text (1) or not (0). Defaults to 0.
@@ -194,13 +286,13 @@ type Text struct {
 	HAlign     *string `xml:"halign,attr,omitempty"`     // Horizontal alignment of the text within the object (left (default), center or right)
 	VAlign     *string `xml:"valign,attr,omitempty"`     // Vertical alignment of the text within the object (top (default), center or bottom)
 
-	Text string `xml:",innerxml"`
+	Text string `xml:",chardata"`
 }
 
 // Image Definition: http://doc.mapeditor.org/en/latest/reference/tmx-map-format/#image
 type Image struct {
 	Format string  `xml:"format,attr,omitempty"` // Used for embedded images, in combination with a data child element. Valid values are file extensions like png, gif, jpg, bmp, etc.
-	Source string  `xml:"source,attr"`           // The reference to the tileset image file (Tiled supports most common image formats).
+	Source string  `xml:"source,attr,omitempty"` // The reference to the tileset image file (Tiled supports most common image formats).
 	Trans  *string `xml:"trans,attr,omitempty"`  // Defines a specific color that is treated as transparent (example value // “#FF00FF” for magenta). Up until Tiled 0.12, this value is written out without a # but this is planned to change.
 	Width  *int    `xml:"width,attr,omitempty"`  // The image width in pixels (optional, used for tile index correction when the image changes)
 	Height *int    `xml:"height,attr,omitempty"` // The image height in pixels (optional)
@@ -238,21 +330,9 @@ func LoadReader(file io.Reader, fileName string) (*Map, error) {
 	err := decoder.Decode(tmxMap)
 	for _, ts := range tmxMap.TileSets {
 		if ts.Source != "" {
-			var source string
-			if filepath.IsAbs(ts.Source) {
-				source = ts.Source
-			} else {
-				source = filepath.Join(filepath.Dir(fileName), ts.Source)
-			}
-			tsxFile, err := os.Open(source)
-			if err != nil {
-				return nil, errors.Wrap(err, "unable to open tileset source file")
-			}
-			d := xml.NewDecoder(tsxFile)
-			err = d.Decode(ts)
-			tsxFile.Close()
+			err := ts.loadTSX(filepath.Dir(fileName))
 			if err != nil {
-				return nil, errors.Wrap(err, "unable to decode tileset source file")
+				return nil, err
 			}
 		}
 	}
@@ -28,7 +28,7 @@ func TestLoad(t *testing.T) {
 	assert.NoError(t, err)
 
 	for iter.Next() {
-		fmt.Printf("%02d ", iter.Get().GID)
+		fmt.Printf("%02d ", iter.Get().GID())
 		if iter.GetIndex()%*layer.Width == *layer.Width-1 {
 			fmt.Println("")
 		}
@@ -47,3 +47,140 @@ func TestLoadTSX(t *testing.T) {
 	assert.EqualValues(t, 1, m.TileSets[0].FirstGID) // from tmx
 	assert.Equal(t, "cave", m.TileSets[0].Name)      // from tsx
 }
+
+func TestLoadModern(t *testing.T) {
+	fp, err := os.Open("resources/modern.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	checkModern := func(m *Map) {
+		require.NotNil(t, m.Class)
+		assert.Equal(t, "dungeon", *m.Class)
+		require.NotNil(t, m.Infinite)
+		assert.Equal(t, 1, *m.Infinite)
+		assert.EqualValues(t, 6, m.NextLayerID)
+		require.NotNil(t, m.ParallaxOriginX)
+		assert.Equal(t, 8.0, *m.ParallaxOriginX)
+		require.NotNil(t, m.CompressionLevel)
+		assert.Equal(t, -1, *m.CompressionLevel)
+
+		ts := m.TileSets[0]
+		assert.Equal(t, "topleft", *ts.ObjectAlignment)
+		assert.Equal(t, "grid", *ts.TileRenderSize)
+		assert.Equal(t, "preserve-aspect-fit", *ts.FillMode)
+		require.NotNil(t, ts.Grid)
+		assert.Equal(t, Grid{Orientation: "isometric", Width: 32, Height: 16}, *ts.Grid)
+
+		ground := m.Layers[0]
+		assert.EqualValues(t, 1, ground.ID)
+		assert.Equal(t, "floor", *ground.Class)
+		assert.Equal(t, 1, *ground.Locked)
+		assert.Equal(t, Color{R: 0xFF, G: 0x80, B: 0x80, A: 0xFF}, *ground.TintColor)
+		assert.Equal(t, "#ff8080", ground.TintColor.String())
+		assert.Equal(t, 0.5, *ground.ParallaxX)
+		assert.Equal(t, 0.75, *ground.ParallaxY)
+		require.Len(t, ground.Data.Chunks, 2)
+
+		sky := m.Layers[1].Layers[0]
+		assert.EqualValues(t, 3, sky.ID)
+		assert.Equal(t, 1, *sky.RepeatX)
+		assert.Nil(t, sky.RepeatY)
+	}
+	checkModern(m)
+
+	out, err := xml.Marshal(m)
+	require.NoError(t, err)
+	m2 := &Map{}
+	require.NoError(t, xml.Unmarshal(out, m2))
+	checkModern(m2)
+}
+
+func TestForEachChunks(t *testing.T) {
+	fp, err := os.Open("resources/modern.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	data := m.Layers[0].Data
+	x, y, w, h, ok := data.Bounds()
+	assert.True(t, ok)
+	assert.Equal(t, []int{-16, 0, 32, 16}, []int{x, y, w, h})
+
+	tiles := make(map[[2]int]uint32)
+	count := 0
+	err = data.ForEach(0, func(x, y int, tile TileInstance) {
+		count++
+		if tile.GID() != 0 {
+			tiles[[2]int{x, y}] = tile.GID()
+		}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2*16*16, count)
+	assert.EqualValues(t, 1, tiles[[2]int{-16, 0}])
+	assert.EqualValues(t, 16, tiles[[2]int{-1, 0}])
+	assert.EqualValues(t, 25, tiles[[2]int{-1, 15}])
+	assert.EqualValues(t, 7, tiles[[2]int{15, 15}])
+	assert.Len(t, tiles, 18)
+
+	// layers and chunks without a width are errors, not a division by zero
+	noop := func(x, y int, tile TileInstance) {}
+	data.Chunks[1].Width = 0
+	assert.Error(t, data.ForEach(0, noop))
+	data.Chunks = nil
+	assert.Error(t, data.ForEach(0, noop))
+	m.Infinite = nil
+	m.Width = 0
+	_, err = m.Layers[0].Data.Region(0, 0, 0, 1, 1)
+	assert.Error(t, err)
+	assert.Error(t, m.ResizeMap(2, 2, AnchorCenter))
+}
+
+func TestXMLAndCSVTiles(t *testing.T) {
+	fp, err := os.Open("resources/modern.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	tiles, err := m.Layers[3].Data.Tiles()
+	require.NoError(t, err)
+	require.Len(t, tiles, 3)
+	assert.EqualValues(t, 1, tiles[0].GID())
+	assert.EqualValues(t, 0, tiles[1].GID())
+	assert.EqualValues(t, 2, tiles[2].GID())
+	assert.True(t, tiles[2].FlippedHorizontally())
+
+	fp2, err := os.Open("resources/cave.tmx")
+	require.NoError(t, err)
+	defer fp2.Close()
+	cave, err := Load(fp2)
+	require.NoError(t, err)
+	tiles, err = cave.Layers[0].Data.Tiles()
+	require.NoError(t, err)
+	assert.Len(t, tiles, 30*30)
+	assert.EqualValues(t, 4, tiles[0].GID())
+}
+
+func TestParallaxOffset(t *testing.T) {
+	fp, err := os.Open("resources/modern.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	fx, fy := m.Layers[0].Parallax()
+	assert.Equal(t, []float64{0.5, 0.75}, []float64{fx, fy})
+	fx, fy = m.Layers[2].Parallax()
+	assert.Equal(t, []float64{1.0, 1.0}, []float64{fx, fy})
+
+	// the map parallax origin is (8, 4)
+	dx, dy := m.ParallaxOffset(0.5, 0.75, 8, 4)
+	assert.Equal(t, []float64{0, 0}, []float64{dx, dy})
+	dx, dy = m.ParallaxOffset(0.5, 0.75, 108, 44)
+	assert.Equal(t, []float64{50, 10}, []float64{dx, dy})
+	dx, dy = m.ParallaxOffset(1, 0, 108, 44)
+	assert.Equal(t, []float64{0, 40}, []float64{dx, dy})
+}
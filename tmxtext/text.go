@@ -0,0 +1,211 @@
+// Package tmxtext lays out the text objects of TMX maps with font faces, it
+// is shared by the renderers so that the core package does not depend on
+// golang.org/x/image.
+package tmxtext
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/elliotmr/tmx"
+	"github.com/pkg/errors"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// FontLoader creates a face of a font for the given pixel size.
+type FontLoader func(size int) (font.Face, error)
+
+type fontStyle struct {
+	family string
+	bold   bool
+	italic bool
+}
+
+// FontRegistry maps the fonts of text objects to font faces. Faces are
+// created on first use and cached. It is safe for concurrent use.
+type FontRegistry struct {
+	Default font.Face // The face used for unregistered fonts, defaults to basicfont.Face7x13.
+
+	mu      sync.Mutex
+	loaders map[fontStyle]FontLoader
+	faces   map[tmx.Font]font.Face
+}
+
+// NewFontRegistry creates an empty registry, every font falls back to the
+// default face until it has been registered.
+func NewFontRegistry() *FontRegistry {
+	return &FontRegistry{
+		Default: basicfont.Face7x13,
+		loaders: make(map[fontStyle]FontLoader),
+		faces:   make(map[tmx.Font]font.Face),
+	}
+}
+
+// Register adds a font family in the given style. For TrueType fonts the
+// loader would typically call truetype.NewFace with the size.
+func (fr *FontRegistry) Register(family string, bold, italic bool, load FontLoader) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.loaders[fontStyle{family, bold, italic}] = load
+	for f := range fr.faces {
+		if f.Family == family {
+			delete(fr.faces, f)
+		}
+	}
+}
+
+// Face returns the face for the font. A family that is not registered in
+// the requested style falls back to its regular style, unknown families
+// fall back to the default face.
+func (fr *FontRegistry) Face(f tmx.Font) (font.Face, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if face, exists := fr.faces[f]; exists {
+		return face, nil
+	}
+	load, exists := fr.loaders[fontStyle{f.Family, f.Bold, f.Italic}]
+	if !exists {
+		load, exists = fr.loaders[fontStyle{f.Family, false, false}]
+	}
+	face := fr.Default
+	if exists {
+		var err error
+		face, err = load(f.Size)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to load font '%s' (%dpx)", f.Family, f.Size)
+		}
+	}
+	if !f.Kerning {
+		face = noKerning{face}
+	}
+	fr.faces[f] = face
+	return face, nil
+}
+
+// noKerning disables the kerning of a face.
+type noKerning struct {
+	font.Face
+}
+
+func (noKerning) Kern(r0, r1 rune) fixed.Int26_6 {
+	return 0
+}
+
+// Line is a line of a text object after layout.
+type Line struct {
+	Text  string
+	X     float64 // The start of the baseline relative to the top-left corner of the object.
+	Y     float64 // The baseline relative to the top-left corner of the object.
+	Width float64
+}
+
+// Layout breaks the text into lines, word wrapped to the width w if wrapping
+// is enabled, and aligns them inside the w x h rectangle of the object.
+func Layout(t *tmx.Text, face font.Face, w, h float64) []Line {
+	metrics := face.Metrics()
+	lineHeight := float64(metrics.Height) / 64
+	ascent := float64(metrics.Ascent) / 64
+	wrap := t.Wrap != nil && *t.Wrap == 1
+
+	var lines []Line
+	for _, paragraph := range strings.Split(t.Text, "\n") {
+		if !wrap {
+			lines = append(lines, Line{Text: paragraph, Width: measure(face, paragraph)})
+			continue
+		}
+		for _, s := range wrapLine(face, paragraph, w) {
+			lines = append(lines, Line{Text: s, Width: measure(face, s)})
+		}
+	}
+
+	y := ascent
+	total := lineHeight * float64(len(lines))
+	if t.VAlign != nil {
+		switch *t.VAlign {
+		case "center":
+			y += (h - total) / 2
+		case "bottom":
+			y += h - total
+		}
+	}
+	for i := range lines {
+		lines[i].Y = y + lineHeight*float64(i)
+		if t.HAlign != nil {
+			switch *t.HAlign {
+			case "center":
+				lines[i].X = (w - lines[i].Width) / 2
+			case "right":
+				lines[i].X = w - lines[i].Width
+			}
+		}
+	}
+	return lines
+}
+
+// measure returns the width of the string including kerning.
+func measure(face font.Face, s string) float64 {
+	return float64(font.MeasureString(face, s)) / 64
+}
+
+// wrapLine breaks s at spaces so that every line fits into w. Words wider
+// than w are broken between runes.
+func wrapLine(face font.Face, s string, w float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Split(s, " ") {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if measure(face, candidate) <= w {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = word
+		for measure(face, line) > w && utf8.RuneCountInString(line) > 1 {
+			i := len(line)
+			for i > 0 && measure(face, line[:i]) > w {
+				_, size := utf8.DecodeLastRuneInString(line[:i])
+				i -= size
+			}
+			if i == 0 {
+				_, i = utf8.DecodeRuneInString(line)
+			}
+			lines = append(lines, line[:i])
+			line = line[i:]
+		}
+	}
+	return append(lines, line)
+}
+
+// Decoration is an underline or strikeout of a Line, as a rectangle
+// relative to the top-left corner of the object.
+type Decoration struct {
+	X, Y, W, H float64
+}
+
+// Decorations returns the underline and strikeout of the line if they are
+// enabled for the text.
+func Decorations(t *tmx.Text, face font.Face, line Line) []Decoration {
+	metrics := face.Metrics()
+	thickness := float64(metrics.Height) / 64 / 16
+	if thickness < 1 {
+		thickness = 1
+	}
+	var decorations []Decoration
+	if t.Underline != nil && *t.Underline == 1 {
+		y := line.Y + float64(metrics.Descent)/64/2
+		decorations = append(decorations, Decoration{line.X, y, line.Width, thickness})
+	}
+	if t.Strikeout != nil && *t.Strikeout == 1 {
+		y := line.Y - float64(metrics.Ascent)/64*0.3
+		decorations = append(decorations, Decoration{line.X, y, line.Width, thickness})
+	}
+	return decorations
+}
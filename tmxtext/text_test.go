@@ -0,0 +1,70 @@
+package tmxtext
+
+import (
+	"testing"
+
+	"github.com/elliotmr/tmx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+)
+
+func TestFontRegistry(t *testing.T) {
+	fr := NewFontRegistry()
+	face, err := fr.Face(tmx.Font{Family: "serif", Size: 16, Kerning: true})
+	require.NoError(t, err)
+	assert.Equal(t, basicfont.Face7x13, face)
+
+	var sizes []int
+	fr.Register("serif", false, false, func(size int) (font.Face, error) {
+		sizes = append(sizes, size)
+		return basicfont.Face7x13, nil
+	})
+	_, err = fr.Face(tmx.Font{Family: "serif", Size: 12, Bold: true, Kerning: true})
+	require.NoError(t, err)
+	_, err = fr.Face(tmx.Font{Family: "serif", Size: 12, Bold: true, Kerning: true})
+	require.NoError(t, err)
+	assert.Equal(t, []int{12}, sizes, "faces are cached")
+
+	face, err = fr.Face(tmx.Font{Family: "serif", Size: 12})
+	require.NoError(t, err)
+	assert.Equal(t, noKerning{basicfont.Face7x13}, face)
+}
+
+func TestLayout(t *testing.T) {
+	face := basicfont.Face7x13 // 7px advance, 13px lines, 11px ascent
+	center, bottom, right := "center", "bottom", "right"
+	wrap := 1
+
+	txt := &tmx.Text{Text: "ab\ncdef", HAlign: &right, VAlign: &bottom}
+	lines := Layout(txt, face, 100, 50)
+	require.Len(t, lines, 2)
+	assert.Equal(t, Line{Text: "ab", X: 86, Y: 50 - 26 + 11, Width: 14}, lines[0])
+	assert.Equal(t, Line{Text: "cdef", X: 72, Y: 50 - 13 + 11, Width: 28}, lines[1])
+
+	txt = &tmx.Text{Text: "one two three", Wrap: &wrap, HAlign: &center, VAlign: &center}
+	lines = Layout(txt, face, 50, 39)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "one two", lines[0].Text)
+	assert.Equal(t, "three", lines[1].Text)
+	assert.Equal(t, 0.5, lines[0].X)
+	assert.Equal(t, 6.5+11, lines[0].Y)
+
+	txt = &tmx.Text{Text: "abcdefgh", Wrap: &wrap}
+	lines = Layout(txt, face, 21, 100)
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"abc", "def", "gh"}, []string{lines[0].Text, lines[1].Text, lines[2].Text})
+}
+
+func TestDecorations(t *testing.T) {
+	on := 1
+	txt := &tmx.Text{Text: "ab", Underline: &on, Strikeout: &on}
+	lines := Layout(txt, basicfont.Face7x13, 100, 100)
+	d := Decorations(txt, basicfont.Face7x13, lines[0])
+	require.Len(t, d, 2)
+	assert.Equal(t, 14.0, d[0].W)
+	assert.True(t, d[0].Y > lines[0].Y, "underline below the baseline")
+	assert.True(t, d[1].Y < lines[0].Y, "strikeout above the baseline")
+	assert.Empty(t, Decorations(&tmx.Text{}, basicfont.Face7x13, lines[0]))
+}
@@ -0,0 +1,440 @@
+package tmx
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// tileTransform is a 2x2 matrix (a, b, c, d) mapping (x, y) to
+// (a*x + b*y, c*x + d*y) in the coordinates of a tile image, with y
+// pointing down.
+type tileTransform [4]int
+
+var (
+	identity = tileTransform{1, 0, 0, 1}
+	flipH    = tileTransform{-1, 0, 0, 1}
+	flipV    = tileTransform{1, 0, 0, -1}
+	flipD    = tileTransform{0, 1, 1, 0}
+	rotateCW = tileTransform{0, -1, 1, 0}
+)
+
+// flagsShift is the position of the lowest of the three flip flags.
+const flagsShift = 29
+
+// mul returns the transformation applying u first and t second.
+func (t tileTransform) mul(u tileTransform) tileTransform {
+	return tileTransform{
+		t[0]*u[0] + t[1]*u[2], t[0]*u[1] + t[1]*u[3],
+		t[2]*u[0] + t[3]*u[2], t[2]*u[1] + t[3]*u[3],
+	}
+}
+
+// flagTransform returns the transformation of a tile image by its flip
+// flags. Like in Tiled, the diagonal flip is applied first, followed by the
+// horizontal and the vertical flip.
+func flagTransform(flags uint32) tileTransform {
+	t := identity
+	if flags&FlippedDiagonallyFlag != 0 {
+		t = flipD.mul(t)
+	}
+	if flags&FlippedHorizontallyFlag != 0 {
+		t = flipH.mul(t)
+	}
+	if flags&FlippedVerticallyFlag != 0 {
+		t = flipV.mul(t)
+	}
+	return t
+}
+
+// apply returns the tile with its flip flags changed so that its image is
+// transformed by t.
+func (t tileTransform) apply(tile TileInstance) TileInstance {
+	if tile.GID() == 0 || t == identity {
+		return tile
+	}
+	want := t.mul(flagTransform(uint32(tile) &^ GIDMask))
+	for i := uint32(0); i < 8; i++ {
+		flags := i << flagsShift
+		if flagTransform(flags) == want {
+			return TileInstance(tile.GID() | flags)
+		}
+	}
+	return tile
+}
+
+// transformable returns an error for infinite maps, which have no edges to
+// transform them around, and for maps that are not orthogonal, whose tiles
+// and objects are not laid out on a rectangular pixel grid.
+func (m *Map) transformable(op string) error {
+	if m.Infinite != nil && *m.Infinite != 0 {
+		return errors.Errorf("infinite maps can not be %s", op)
+	}
+	if m.Orientation != "orthogonal" {
+		return errors.Errorf("%s maps can not be %s", m.Orientation, op)
+	}
+	return nil
+}
+
+// Crop cuts the map down to the rectangle of width x height tiles at (x, y),
+// which must be within the map. Unlike ResizeMap, objects outside of the
+// rectangle are removed. Image layers are moved along with the tiles by
+// changing their offsets. Only finite orthogonal maps can be cropped.
+func (m *Map) Crop(x, y, width, height int) error {
+	err := m.transformable("cropped")
+	if err != nil {
+		return err
+	}
+	if x < 0 || y < 0 || width <= 0 || height <= 0 || x+width > int(m.Width) || y+height > int(m.Height) {
+		return errors.Errorf("crop rectangle (%d, %d, %d, %d) outside of the map", x, y, width, height)
+	}
+	err = m.reframe(uint32(width), uint32(height), -x, -y)
+	if err != nil {
+		return err
+	}
+	pw, ph := float64(m.Width*m.TileWidth), float64(m.Height*m.TileHeight)
+	m.walkLayers(func(l, parent *Layer) {
+		var kept []*Object
+		for _, obj := range l.Objects {
+			if obj.X >= 0 && obj.X <= pw && obj.Y >= 0 && obj.Y <= ph {
+				kept = append(kept, obj)
+			}
+		}
+		l.Objects = kept
+	})
+	return nil
+}
+
+// FlipHorizontally mirrors the map from left to right. Tiles are moved and
+// their flip flags changed, objects are mirrored with their rotation,
+// polygon points and tile flip flags. TMX can not mirror the images of image
+// layers, they are only moved to the mirrored position, which requires the
+// size of their images. Only finite orthogonal maps can be flipped.
+func (m *Map) FlipHorizontally() error {
+	return m.flip(true)
+}
+
+// FlipVertically mirrors the map from top to bottom, see FlipHorizontally.
+func (m *Map) FlipVertically() error {
+	return m.flip(false)
+}
+
+func (m *Map) flip(horizontal bool) error {
+	err := m.transformable("flipped")
+	if err != nil {
+		return err
+	}
+	w, h := int(m.Width), int(m.Height)
+	move := func(x, y int) (int, int) {
+		if horizontal {
+			return w - 1 - x, y
+		}
+		return x, h - 1 - y
+	}
+	t := flipV
+	if horizontal {
+		t = flipH
+	}
+	err = m.checkPoints()
+	if err != nil {
+		return err
+	}
+	images, err := m.imageSizes("flipped")
+	if err != nil {
+		return err
+	}
+	err = m.transformTiles(w, h, move, t)
+	if err != nil {
+		return err
+	}
+	pw, ph := float64(w)*float64(m.TileWidth), float64(h)*float64(m.TileHeight)
+	m.walkLayers(func(l, parent *Layer) {
+		x, y := layerOffset(l)
+		size, isImage := images[l]
+		switch {
+		case horizontal && isImage:
+			x = clean(pw - x - size[0])
+			l.OffsetX = &x
+		case !horizontal && isImage:
+			y = clean(ph - y - size[1])
+			l.OffsetY = &y
+		case horizontal && l.OffsetX != nil:
+			*l.OffsetX = clean(-x)
+		case !horizontal && l.OffsetY != nil:
+			*l.OffsetY = clean(-y)
+		}
+		for _, obj := range l.Objects {
+			m.flipObject(obj, horizontal, pw, ph)
+		}
+	})
+	return nil
+}
+
+// flipObject mirrors an object within a map of pw x ph pixels. The mirrored
+// object is moved along its rotated axes so that its anchor is at the same
+// corner as before. The points of the object must have been checked by
+// checkPoints.
+func (m *Map) flipObject(obj *Object, horizontal bool, pw, ph float64) {
+	fx, fy := m.objectAnchor(obj)
+	w, h := 0.0, 0.0
+	if obj.Width != nil {
+		w = *obj.Width
+	}
+	if obj.Height != nil {
+		h = *obj.Height
+	}
+	rotation := 0.0
+	if obj.Rotation != nil {
+		rotation = -*obj.Rotation
+	}
+	setRotation(obj, rotation)
+	sin, cos := math.Sincos(rotation * math.Pi / 180)
+
+	var t tileTransform
+	var mirror func(x, y float64) (float64, float64)
+	if horizontal {
+		shift := (2*fx - 1) * w
+		obj.X, obj.Y = clean(pw-obj.X+shift*cos), clean(obj.Y+shift*sin)
+		t, mirror = flipH, func(x, y float64) (float64, float64) { return -x, y }
+	} else {
+		shift := (2*fy - 1) * h
+		obj.X, obj.Y = clean(obj.X-shift*sin), clean(ph-obj.Y+shift*cos)
+		t, mirror = flipV, func(x, y float64) (float64, float64) { return x, -y }
+	}
+	if obj.GID != nil {
+		gid := uint32(t.apply(TileInstance(*obj.GID)))
+		obj.GID = &gid
+	}
+	obj.transformPoints(mirror)
+}
+
+// objectAnchor returns the point of the object at its position as fractions
+// of its size, see TileSet.ObjectAnchor.
+func (m *Map) objectAnchor(obj *Object) (float64, float64) {
+	if obj.GID == nil {
+		return 0.0, 0.0
+	}
+	ts := m.TileSetFor(TileInstance(*obj.GID).GID())
+	if ts == nil {
+		ts = &TileSet{}
+	}
+	return ts.ObjectAnchor(m.Orientation)
+}
+
+// Rotate turns the map clockwise by quarterTurns steps of 90°, negative
+// steps turn it counterclockwise. Tiles are moved and their flip flags
+// changed, objects are rotated around their position. TMX can not rotate
+// the images of image layers, they are only moved to the rotated position,
+// which requires the size of their images. Only finite orthogonal maps can
+// be rotated, turning by an odd number of steps requires square tiles.
+func (m *Map) Rotate(quarterTurns int) error {
+	err := m.transformable("rotated")
+	if err != nil {
+		return err
+	}
+	turns := (quarterTurns%4 + 4) % 4
+	if turns%2 == 1 && m.TileWidth != m.TileHeight {
+		return errors.Errorf("tiles of %dx%d pixels can not be rotated by 90°", m.TileWidth, m.TileHeight)
+	}
+	images, err := m.imageSizes("rotated")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < turns; i++ {
+		err = m.rotateOnce(images)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateOnce turns the map clockwise by 90°, images holds the image sizes
+// of the image layers.
+func (m *Map) rotateOnce(images map[*Layer][2]float64) error {
+	w, h := int(m.Width), int(m.Height)
+	err := m.transformTiles(h, w, func(x, y int) (int, int) { return h - 1 - y, x }, rotateCW)
+	if err != nil {
+		return err
+	}
+	ph := float64(h) * float64(m.TileHeight)
+	m.walkLayers(func(l, parent *Layer) {
+		x, y := layerOffset(l)
+		if size, isImage := images[l]; isImage {
+			// the image keeps its orientation and is turned around its
+			// center, so that turning it four times restores the offset
+			cx, cy := x+size[0]/2, y+size[1]/2
+			x, y = clean(ph-cy-size[0]/2), clean(cx-size[1]/2)
+			l.OffsetX, l.OffsetY = &x, &y
+		} else if l.OffsetX != nil || l.OffsetY != nil {
+			x, y = clean(-y), x
+			l.OffsetX, l.OffsetY = &x, &y
+		}
+		for _, obj := range l.Objects {
+			obj.X, obj.Y = clean(ph-obj.Y), obj.X
+			rotation := 90.0
+			if obj.Rotation != nil {
+				rotation += *obj.Rotation
+			}
+			setRotation(obj, rotation)
+		}
+	})
+	m.Width, m.Height = uint32(h), uint32(w)
+	return nil
+}
+
+// imageSizes returns the size in pixels of the images of all image layers,
+// which is needed to move them. Image layers without an image size can not
+// be moved.
+func (m *Map) imageSizes(op string) (map[*Layer][2]float64, error) {
+	sizes := make(map[*Layer][2]float64)
+	var err error
+	m.walkLayers(func(l, parent *Layer) {
+		if err != nil || l.XMLName.Local != "imagelayer" || l.Image == nil {
+			return
+		}
+		img := l.Image
+		if img.Width == nil || img.Height == nil || *img.Width <= 0 || *img.Height <= 0 {
+			err = errors.Errorf("image layer '%s' without an image size can not be %s", l.Name, op)
+			return
+		}
+		sizes[l] = [2]float64{float64(*img.Width), float64(*img.Height)}
+	})
+	return sizes, err
+}
+
+// layerOffset returns the offset of a layer in pixels.
+func layerOffset(l *Layer) (float64, float64) {
+	x, y := 0.0, 0.0
+	if l.OffsetX != nil {
+		x = *l.OffsetX
+	}
+	if l.OffsetY != nil {
+		y = *l.OffsetY
+	}
+	return x, y
+}
+
+// transformTiles moves the tiles of all tile layers into layers of
+// width x height tiles. move returns the new position of a tile, tiles
+// moved outside of the layers are dropped. The images of the tiles are
+// transformed by t. The layers are only changed once all of them have been
+// encoded.
+func (m *Map) transformTiles(width, height int, move func(x, y int) (int, int), t tileTransform) error {
+	blocks, err := m.decodeTiles()
+	if err != nil {
+		return err
+	}
+	for i := range blocks {
+		b := &blocks[i]
+		if b.width <= 0 {
+			return errors.Errorf("layer '%s' has no width", b.layer.Name)
+		}
+		moved := make([]TileInstance, width*height)
+		for j, tile := range b.tiles {
+			x, y := move(j%b.width, j/b.width)
+			if x >= 0 && x < width && y >= 0 && y < height {
+				moved[y*width+x] = t.apply(tile)
+			}
+		}
+		b.width, b.tiles = width, moved
+		err = b.encode()
+		if err != nil {
+			return errors.Wrapf(err, "unable to encode layer '%s'", b.layer.Name)
+		}
+	}
+	w, h := uint32(width), uint32(height)
+	for i := range blocks {
+		blocks[i].set()
+		blocks[i].layer.Width, blocks[i].layer.Height = &w, &h
+	}
+	return nil
+}
+
+// checkPoints returns an error if the points of a polygon or polyline object
+// can not be parsed, so that they can be transformed after the tiles.
+func (m *Map) checkPoints() error {
+	var err error
+	m.walkLayers(func(l, parent *Layer) {
+		for _, obj := range l.Objects {
+			if err != nil {
+				return
+			}
+			err = obj.transformPoints(nil)
+			if err != nil {
+				err = errors.Wrapf(err, "invalid points of object %d", obj.ID)
+			}
+		}
+	})
+	return err
+}
+
+// transformPoints changes the points of a polygon or polyline object. A nil
+// fn only checks the points and leaves them unchanged.
+func (obj *Object) transformPoints(fn func(x, y float64) (float64, float64)) error {
+	if obj.Polygon != nil {
+		points, err := transformPoints(obj.Polygon.Points, fn)
+		if err != nil {
+			return err
+		}
+		if fn != nil {
+			obj.Polygon.Points = points
+		}
+	}
+	if obj.Polyline != nil {
+		points, err := transformPoints(obj.Polyline.Points, fn)
+		if err != nil {
+			return err
+		}
+		if fn != nil {
+			obj.Polyline.Points = points
+		}
+	}
+	return nil
+}
+
+// transformPoints changes every point of a list of x,y coordinates, a nil
+// fn keeps them.
+func transformPoints(points string, fn func(x, y float64) (float64, float64)) (string, error) {
+	fields := strings.Fields(points)
+	for i, field := range fields {
+		pt := strings.Split(field, ",")
+		if len(pt) != 2 {
+			return "", errors.Errorf("invalid point '%s'", field)
+		}
+		x, err := strconv.ParseFloat(pt[0], 64)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid x-axis point")
+		}
+		y, err := strconv.ParseFloat(pt[1], 64)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid y-axis point")
+		}
+		if fn != nil {
+			x, y = fn(x, y)
+		}
+		fields[i] = strconv.FormatFloat(clean(x), 'f', -1, 64) + "," + strconv.FormatFloat(clean(y), 'f', -1, 64)
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// setRotation sets the rotation of an object in degrees, normalized to less
+// than a full turn. A rotation of 0 is left out.
+func setRotation(obj *Object, rotation float64) {
+	rotation = math.Mod(rotation, 360)
+	if rotation == 0 {
+		obj.Rotation = nil
+		return
+	}
+	obj.Rotation = &rotation
+}
+
+// clean turns -0 into 0, so it is not written as "-0".
+func clean(v float64) float64 {
+	if v == 0 {
+		return 0
+	}
+	return v
+}
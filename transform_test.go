@@ -0,0 +1,188 @@
+package tmx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTransformMap(t *testing.T) (*Map, *Layer, *Layer) {
+	m := &Map{Orientation: "orthogonal", Width: 3, Height: 2, TileWidth: 16, TileHeight: 16}
+	require.NoError(t, m.AddTileSet(&TileSet{Name: "a", TileCount: 8}))
+	ground := NewTileLayer("ground")
+	require.NoError(t, m.AddLayer(nil, -1, ground))
+	require.NoError(t, ground.Data.Encode(3, []TileInstance{
+		1, 2, 3,
+		4, 5, TileInstance(FlippedHorizontallyFlag | 6),
+	}))
+	things := NewObjectGroup("things")
+	require.NoError(t, m.AddLayer(nil, -1, things))
+	w, h := 8.0, 4.0
+	gid := uint32(2)
+	tw, th := 16.0, 16.0
+	for _, obj := range []*Object{
+		{X: 2, Y: 3, Width: &w, Height: &h},
+		{X: 10, Y: 20, Polygon: &Polygon{Points: "0,0 4,0 4,-2"}},
+		{X: 16, Y: 32, Width: &tw, Height: &th, GID: &gid},
+	} {
+		require.NoError(t, m.AddObject(things, obj))
+	}
+	return m, ground, things
+}
+
+func TestTileTransform(t *testing.T) {
+	// rotating clockwise matches the masks used by Tiled
+	for flags, want := range []uint32{5, 4, 1, 0, 7, 6, 3, 2} {
+		h, v, d := uint32(flags>>2&1), uint32(flags>>1&1), uint32(flags&1)
+		tile := TileInstance(h*FlippedHorizontallyFlag | v*FlippedVerticallyFlag | d*FlippedDiagonallyFlag | 3)
+		rotated := rotateCW.apply(tile)
+		assert.EqualValues(t, 3, rotated.GID())
+		mask := uint32(0)
+		for i, set := range []bool{rotated.FlippedHorizontally(), rotated.FlippedVertically(), rotated.FlippedDiagonally()} {
+			if set {
+				mask |= 4 >> uint(i)
+			}
+		}
+		assert.Equal(t, want, mask, "flags %d", flags)
+	}
+	assert.Equal(t, TileInstance(0), flipH.apply(0))
+}
+
+func TestFlipHorizontally(t *testing.T) {
+	m, ground, things := newTransformMap(t)
+	require.NoError(t, m.FlipHorizontally())
+	tiles, err := ground.Data.Tiles()
+	require.NoError(t, err)
+	h := func(gid uint32) TileInstance { return TileInstance(FlippedHorizontallyFlag | gid) }
+	assert.Equal(t, []TileInstance{h(3), h(2), h(1), 6, h(5), h(4)}, tiles)
+
+	rect, polygon, tile := things.Objects[0], things.Objects[1], things.Objects[2]
+	assert.Equal(t, []float64{38, 3}, []float64{rect.X, rect.Y})
+	assert.Equal(t, []float64{38, 20}, []float64{polygon.X, polygon.Y})
+	assert.Equal(t, "0,0 -4,0 -4,-2", polygon.Polygon.Points)
+	assert.Equal(t, []float64{16, 32}, []float64{tile.X, tile.Y})
+	assert.Equal(t, FlippedHorizontallyFlag|2, *tile.GID)
+
+	// flipping twice restores the map, also for rotated objects
+	rotation := 30.0
+	rect.Rotation = &rotation
+	require.NoError(t, m.FlipHorizontally())
+	assert.Equal(t, -30.0, *rect.Rotation)
+	require.NoError(t, m.FlipHorizontally())
+	assert.Equal(t, 30.0, *rect.Rotation)
+	assert.InDelta(t, 38, rect.X, 1e-9)
+	assert.InDelta(t, 3, rect.Y, 1e-9)
+
+	// image layers are moved to the mirrored position of their image
+	iw, ih := 20, 10
+	img := NewImageLayer("sky", &Image{Source: "sky.png", Width: &iw, Height: &ih})
+	require.NoError(t, m.AddLayer(nil, -1, img))
+	require.NoError(t, m.FlipHorizontally())
+	assert.Equal(t, 28.0, *img.OffsetX)
+	assert.Nil(t, img.OffsetY)
+	img.Image.Width = nil
+	assert.Error(t, m.FlipHorizontally())
+}
+
+func TestFlipVertically(t *testing.T) {
+	m, ground, things := newTransformMap(t)
+	require.NoError(t, m.FlipVertically())
+	tiles, err := ground.Data.Tiles()
+	require.NoError(t, err)
+	v := func(gid uint32) TileInstance { return TileInstance(FlippedVerticallyFlag | gid) }
+	hv := TileInstance(FlippedHorizontallyFlag | FlippedVerticallyFlag | 6)
+	assert.Equal(t, []TileInstance{v(4), v(5), hv, v(1), v(2), v(3)}, tiles)
+
+	rect, polygon, tile := things.Objects[0], things.Objects[1], things.Objects[2]
+	assert.Equal(t, []float64{2, 25}, []float64{rect.X, rect.Y})
+	assert.Equal(t, []float64{10, 12}, []float64{polygon.X, polygon.Y})
+	assert.Equal(t, "0,0 4,0 4,2", polygon.Polygon.Points)
+	// tile objects are anchored at their bottom-left corner
+	assert.Equal(t, []float64{16, 16}, []float64{tile.X, tile.Y})
+	assert.Equal(t, FlippedVerticallyFlag|2, *tile.GID)
+}
+
+func TestRotate(t *testing.T) {
+	m, ground, things := newTransformMap(t)
+	iw, ih := 20, 10
+	ox, oy := 4.0, 6.0
+	img := NewImageLayer("sky", &Image{Source: "sky.png", Width: &iw, Height: &ih})
+	img.OffsetX, img.OffsetY = &ox, &oy
+	require.NoError(t, m.AddLayer(nil, -1, img))
+	require.NoError(t, m.Rotate(1))
+	assert.EqualValues(t, []uint32{2, 3}, []uint32{m.Width, m.Height})
+	assert.EqualValues(t, 2, *ground.Width)
+	tiles, err := ground.Data.Tiles()
+	require.NoError(t, err)
+	r := func(tile TileInstance) TileInstance { return rotateCW.apply(tile) }
+	assert.Equal(t, []TileInstance{
+		r(4), r(1),
+		r(5), r(2),
+		r(TileInstance(FlippedHorizontallyFlag | 6)), r(3),
+	}, tiles)
+	assert.Equal(t, TileInstance(FlippedHorizontallyFlag|FlippedDiagonallyFlag|1), tiles[1])
+
+	rect := things.Objects[0]
+	assert.Equal(t, []float64{29, 2}, []float64{rect.X, rect.Y})
+	assert.Equal(t, 90.0, *rect.Rotation)
+	// the image of an image layer keeps its orientation
+	assert.Equal(t, []float64{11, 9}, []float64{*img.OffsetX, *img.OffsetY})
+
+	// four quarter turns restore the map
+	require.NoError(t, m.Rotate(-1))
+	require.NoError(t, m.Rotate(6))
+	require.NoError(t, m.Rotate(2))
+	assert.EqualValues(t, []uint32{3, 2}, []uint32{m.Width, m.Height})
+	tiles, err = ground.Data.Tiles()
+	require.NoError(t, err)
+	assert.Equal(t, []TileInstance{1, 2, 3, 4, 5, TileInstance(FlippedHorizontallyFlag | 6)}, tiles)
+	assert.Equal(t, []float64{2, 3}, []float64{rect.X, rect.Y})
+	assert.Nil(t, rect.Rotation)
+	assert.Equal(t, []float64{4, 6}, []float64{*img.OffsetX, *img.OffsetY})
+	assert.Equal(t, "0,0 4,0 4,-2", things.Objects[1].Polygon.Points)
+
+	m.TileHeight = 8
+	assert.Error(t, m.Rotate(1))
+	assert.NoError(t, m.Rotate(2))
+}
+
+func TestCrop(t *testing.T) {
+	m, ground, things := newTransformMap(t)
+	require.NoError(t, m.Crop(1, 0, 2, 2))
+	tiles, err := ground.Data.Tiles()
+	require.NoError(t, err)
+	assert.Equal(t, []TileInstance{2, 3, 5, TileInstance(FlippedHorizontallyFlag | 6)}, tiles)
+	require.Len(t, things.Objects, 1)
+	assert.Equal(t, []float64{0, 32}, []float64{things.Objects[0].X, things.Objects[0].Y})
+
+	// image layers are moved along with the tiles
+	img := NewImageLayer("sky", &Image{Source: "sky.png"})
+	require.NoError(t, m.AddLayer(nil, -1, img))
+	require.NoError(t, m.Crop(1, 1, 1, 1))
+	assert.Equal(t, []float64{-16, -16}, []float64{*img.OffsetX, *img.OffsetY})
+
+	assert.Error(t, m.Crop(1, 1, 2, 2))
+	infinite := 1
+	m.Infinite = &infinite
+	assert.Error(t, m.Crop(0, 0, 1, 1))
+	assert.Error(t, m.FlipHorizontally())
+	assert.Error(t, m.Rotate(1))
+}
+
+func TestTransformErrors(t *testing.T) {
+	m, ground, things := newTransformMap(t)
+	things.Objects[1].Polygon.Points = "0,0 4,x"
+	before, err := ground.Data.Tiles()
+	require.NoError(t, err)
+	assert.Error(t, m.FlipHorizontally())
+	tiles, err := ground.Data.Tiles()
+	require.NoError(t, err)
+	assert.Equal(t, before, tiles)
+	assert.Equal(t, 2.0, things.Objects[0].X)
+
+	m.Orientation = "staggered"
+	assert.Error(t, m.FlipVertically())
+	assert.Error(t, m.Rotate(2))
+	assert.Error(t, m.Crop(0, 0, 1, 1))
+}
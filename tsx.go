@@ -0,0 +1,163 @@
+package tmx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// tileSet has the fields of TileSet without its methods, it is used to
+// encode the full contents of a tileset.
+type tileSet TileSet
+
+// tsxTileSet is the root element of a TSX file, which has neither a firstgid
+// nor a source attribute. The fields shadow those of the embedded tileset.
+type tsxTileSet struct {
+	XMLName  xml.Name `xml:"tileset"`
+	FirstGID *uint32  `xml:"firstgid,attr,omitempty"`
+	Source   *string  `xml:"source,attr,omitempty"`
+	*tileSet
+}
+
+// MarshalXML implements xml.Marshaler. External tilesets are only written as
+// a reference to their TSX file, their contents are written by SaveTileSets.
+func (ts *TileSet) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if ts.Source == "" {
+		return e.EncodeElement((*tileSet)(ts), start)
+	}
+	start.Attr = []xml.Attr{
+		{Name: xml.Name{Local: "firstgid"}, Value: fmt.Sprint(ts.FirstGID)},
+		{Name: xml.Name{Local: "source"}, Value: ts.Source},
+	}
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Externalize turns an embedded tileset into an external one stored in the
+// TSX file at source, which is relative to the directory of the map. The
+// file is written by Map.SaveTileSets.
+func Externalize(ts *TileSet, source string) error {
+	if ts.Source != "" {
+		return errors.Errorf("tileset '%s' is already stored in '%s'", ts.Name, ts.Source)
+	}
+	if source == "" {
+		return errors.New("empty tileset source")
+	}
+	ts.Source = source
+	return nil
+}
+
+// Embed turns an external tileset into one stored in the map. The contents
+// of the TSX file have already been loaded with the map, so only the
+// reference is dropped.
+func Embed(ts *TileSet) error {
+	if ts.Source == "" {
+		return errors.Errorf("tileset '%s' is already embedded", ts.Name)
+	}
+	ts.Source = ""
+	return nil
+}
+
+// loadTSX decodes the TSX file of an external tileset into it, dir is the
+// directory of the map. Image sources in the TSX file are relative to the
+// TSX file, they are rebased onto the directory of the map like those of
+// embedded tilesets.
+func (ts *TileSet) loadTSX(dir string) error {
+	f, err := os.Open(resolvePath(dir, ts.Source))
+	if err != nil {
+		return errors.Wrap(err, "unable to open tileset source file")
+	}
+	defer f.Close()
+	err = xml.NewDecoder(f).Decode(ts)
+	if err != nil {
+		return errors.Wrap(err, "unable to decode tileset source file")
+	}
+	from := filepath.Dir(ts.Source)
+	*ts = *ts.rebaseImages(func(source string) (string, bool) {
+		return filepath.Join(from, source), !filepath.IsAbs(source)
+	})
+	return nil
+}
+
+// rebaseImages returns a copy of the tileset with the sources of its image
+// files changed by fn. Sources are only changed if the TSX file is in a
+// different directory than the map, the tileset itself is left untouched.
+func (ts *TileSet) rebaseImages(fn func(source string) (string, bool)) *TileSet {
+	cp := *ts
+	from := filepath.Dir(ts.Source)
+	if ts.Source == "" || from == "." {
+		return &cp
+	}
+	rebase := func(img *Image) *Image {
+		if img == nil || img.Embedded() {
+			return img
+		}
+		source, ok := fn(img.Source)
+		if !ok {
+			return img
+		}
+		rebased := *img
+		rebased.Source = filepath.ToSlash(source)
+		return &rebased
+	}
+	cp.Image = rebase(ts.Image)
+	cp.Tiles = make([]*Tile, len(ts.Tiles))
+	for i, tile := range ts.Tiles {
+		cp.Tiles[i] = tile
+		if img := rebase(tile.Image); img != tile.Image {
+			t := *tile
+			t.Image = img
+			cp.Tiles[i] = &t
+		}
+	}
+	if ts.Tiles == nil {
+		cp.Tiles = nil
+	}
+	return &cp
+}
+
+// WriteTSX writes the contents of the tileset as a TSX file. Image sources,
+// which are relative to the directory of the map, are written relative to
+// the directory of the TSX file given by Source.
+func (ts *TileSet) WriteTSX(w io.Writer) error {
+	from := filepath.Dir(ts.Source)
+	cp := ts.rebaseImages(func(source string) (string, bool) {
+		rel, err := filepath.Rel(from, source)
+		return rel, err == nil
+	})
+	return errors.Wrap(writeXML(w, tsxTileSet{tileSet: (*tileSet)(cp)}), "unable to write tileset")
+}
+
+// SaveTileSets writes the TSX files of the external tilesets of the map
+// whose contents differ from the files in dir, the directory of the map.
+// Unchanged files are not touched, missing files are created.
+func (m *Map) SaveTileSets(dir string) error {
+	for _, ts := range m.TileSets {
+		if ts.Source == "" {
+			continue
+		}
+		saved := &TileSet{FirstGID: ts.FirstGID, Source: ts.Source}
+		if saved.loadTSX(dir) == nil && reflect.DeepEqual(saved, ts) {
+			continue
+		}
+		var buf bytes.Buffer
+		err := ts.WriteTSX(&buf)
+		if err == nil {
+			err = ioutil.WriteFile(resolvePath(dir, ts.Source), buf.Bytes(), 0644)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to save tileset '%s'", ts.Name)
+		}
+	}
+	return nil
+}
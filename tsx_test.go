@@ -0,0 +1,80 @@
+package tmx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalExternalTileSet(t *testing.T) {
+	fp, err := os.Open("resources/cave.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	out, err := xml.Marshal(m)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `<tileset firstgid="1" source="cave.tsx"></tileset>`)
+	assert.NotContains(t, string(out), "terraintypes")
+
+	require.NoError(t, Embed(m.TileSets[0]))
+	assert.Error(t, Embed(m.TileSets[0]))
+	out, err = xml.Marshal(m)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `<tileset firstgid="1" name="cave"`)
+	assert.Contains(t, string(out), `<image source="cave.png"`)
+}
+
+func TestExternalize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tmx-tsx")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "tilesets"), 0755))
+
+	fp, err := os.Open("resources/collection.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+	ts := m.TileSets[0]
+	require.NoError(t, Externalize(ts, "tilesets/collection.tsx"))
+	assert.Error(t, Externalize(ts, "other.tsx"))
+	require.NoError(t, m.SaveTileSets(dir))
+
+	path := filepath.Join(dir, "tilesets", "collection.tsx")
+	tsx, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(tsx, []byte(xml.Header+`<tileset name="collection"`)))
+	assert.NotContains(t, string(tsx), "firstgid")
+	assert.Contains(t, string(tsx), `source="../cave.png"`)
+
+	// image sources are relative to the map again after loading
+	out, err := xml.Marshal(m)
+	require.NoError(t, err)
+	loaded, err := LoadReader(bytes.NewReader(out), filepath.Join(dir, "collection.tmx"))
+	require.NoError(t, err)
+	assert.Equal(t, ts, loaded.TileSets[0])
+	assert.Equal(t, "cave.png", loaded.TileSets[0].Tiles[0].Image.Source)
+
+	// unchanged files are not written again
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(path, past, past))
+	require.NoError(t, loaded.SaveTileSets(dir))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, fi.ModTime().Equal(past))
+
+	loaded.TileSets[0].Name = "renamed"
+	require.NoError(t, loaded.SaveTileSets(dir))
+	tsx, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(tsx), `name="renamed"`)
+}
@@ -0,0 +1,125 @@
+package tmx
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultWatchInterval is the default minimum time between two polls of a
+// Watcher.
+const DefaultWatchInterval = 500 * time.Millisecond
+
+// Dependencies returns the files the map was loaded from besides the map
+// file itself: external tilesets, object templates and external images. The
+// paths are resolved against dir, the directory of the map file.
+func (m *Map) Dependencies(dir string) []string {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, set := range m.TileSets {
+		if set.Source != "" {
+			add(resolvePath(dir, set.Source))
+		}
+	}
+	var walk func(layers []*Layer)
+	walk = func(layers []*Layer) {
+		for _, l := range layers {
+			for _, obj := range l.Objects {
+				if obj.Template != nil {
+					add(resolvePath(dir, *obj.Template))
+				}
+			}
+			walk(l.Layers)
+		}
+	}
+	walk(m.Layers)
+	for _, img := range m.Images() {
+		if !img.Embedded() {
+			add(img.Key(dir))
+		}
+	}
+	return files
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func stat(file string) fileStamp {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size(), exists: true}
+}
+
+// Watcher polls a map file and the files it depends on for changes. It does
+// not start any goroutines, Changed is meant to be called once per frame and
+// only touches the file system once the interval has passed.
+type Watcher struct {
+	Interval time.Duration // The minimum time between two polls. Defaults to DefaultWatchInterval.
+
+	path  string
+	files map[string]fileStamp
+	last  time.Time
+}
+
+// NewWatcher creates a Watcher for the map file at path and the files of the
+// map loaded from it.
+func NewWatcher(path string, m *Map) *Watcher {
+	w := &Watcher{Interval: DefaultWatchInterval, path: path}
+	w.Watch(m)
+	return w
+}
+
+// Watch replaces the watched files with the map file and the current
+// dependencies of m, it is called after the map has been reloaded.
+func (w *Watcher) Watch(m *Map) {
+	w.files = map[string]fileStamp{w.path: stat(w.path)}
+	for _, file := range m.Dependencies(filepath.Dir(w.path)) {
+		w.files[file] = stat(file)
+	}
+	w.last = time.Now()
+}
+
+// Add also watches the dependencies of m and keeps watching the files that
+// are already watched, e.g. after a new version of the map failed to load,
+// so that fixing any file of either version is noticed. Watch replaces the
+// files again once the map has been reloaded.
+func (w *Watcher) Add(m *Map) {
+	for _, file := range m.Dependencies(filepath.Dir(w.path)) {
+		if _, ok := w.files[file]; !ok {
+			w.files[file] = stat(file)
+		}
+	}
+}
+
+// Changed returns true if any of the watched files has been modified,
+// created or removed since the last poll.
+func (w *Watcher) Changed() bool {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	if time.Since(w.last) < interval {
+		return false
+	}
+	w.last = time.Now()
+	changed := false
+	for file, old := range w.files {
+		s := stat(file)
+		if s.exists != old.exists || s.size != old.size || !s.modTime.Equal(old.modTime) {
+			w.files[file] = s
+			changed = true
+		}
+	}
+	return changed
+}
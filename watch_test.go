@@ -0,0 +1,87 @@
+package tmx
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencies(t *testing.T) {
+	fp, err := os.Open("resources/cave.tmx")
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join("resources", "cave.tsx"),
+		filepath.Join("resources", "cave.png"),
+	}, m.Dependencies("resources"))
+}
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tmx-watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"cave.tmx", "cave.tsx"} {
+		data, err := ioutil.ReadFile(filepath.Join("resources", name))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+
+	path := filepath.Join(dir, "cave.tmx")
+	fp, err := os.Open(path)
+	require.NoError(t, err)
+	defer fp.Close()
+	m, err := Load(fp)
+	require.NoError(t, err)
+
+	w := NewWatcher(path, m)
+	w.Interval = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	assert.False(t, w.Changed())
+
+	tsx := filepath.Join(dir, "cave.tsx")
+	require.NoError(t, ioutil.WriteFile(tsx, []byte("<tileset/>"), 0644))
+	time.Sleep(time.Millisecond)
+	assert.True(t, w.Changed())
+	time.Sleep(time.Millisecond)
+	assert.False(t, w.Changed())
+
+	// the image does not exist yet, creating it counts as a change
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cave.png"), []byte("png"), 0644))
+	time.Sleep(time.Millisecond)
+	assert.True(t, w.Changed())
+
+	w.Interval = time.Hour
+	require.NoError(t, os.Remove(tsx))
+	assert.False(t, w.Changed())
+}
+
+func TestWatcherAdd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tmx-watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "map.tmx")
+	require.NoError(t, ioutil.WriteFile(path, []byte("<map/>"), 0644))
+
+	w := NewWatcher(path, &Map{})
+	w.Interval = time.Nanosecond
+	// a version of the map that failed to load because its tileset is missing
+	w.Add(&Map{TileSets: []*TileSet{{Source: "missing.tsx"}}})
+	time.Sleep(time.Millisecond)
+	assert.False(t, w.Changed())
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "missing.tsx"), []byte("<tileset/>"), 0644))
+	time.Sleep(time.Millisecond)
+	assert.True(t, w.Changed())
+
+	// the map file itself stays watched
+	require.NoError(t, ioutil.WriteFile(path, []byte("<map></map>"), 0644))
+	time.Sleep(time.Millisecond)
+	assert.True(t, w.Changed())
+}
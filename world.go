@@ -0,0 +1,279 @@
+package tmx
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// World Definition: https://doc.mapeditor.org/en/stable/manual/worlds/
+// A world places many maps in one coordinate space in pixels. The maps are
+// only loaded when they are first needed.
+type World struct {
+	Maps                 []*WorldMap    `json:"maps"`                 // The maps of the world, including those found by the patterns.
+	Patterns             []WorldPattern `json:"patterns,omitempty"`   // Patterns placing all matching maps in the directory of the world.
+	OnlyShowAdjacentMaps bool           `json:"onlyShowAdjacentMaps"` // Whether Tiled only shows the maps next to the current one.
+	Type                 string         `json:"type"`                 // Always “world”.
+
+	dir string
+}
+
+// WorldMap is a map placed in a world. If the world does not give its size,
+// it is read from the header of the map file when the world is loaded.
+// Infinite maps have no size in their header, they need a size in the world
+// file to be found by the lookups of the world.
+type WorldMap struct {
+	FileName string `json:"fileName"` // The path of the map file relative to the world file.
+	X        int    `json:"x"`        // The x coordinate of the map in the world in pixels.
+	Y        int    `json:"y"`        // The y coordinate of the map in the world in pixels.
+	Width    int    `json:"width"`    // The width of the map in pixels.
+	Height   int    `json:"height"`   // The height of the map in pixels.
+
+	path string
+	mu   sync.Mutex
+	m    *Map
+}
+
+// WorldPattern places all maps whose file name matches a regular expression.
+// The first two groups of the expression are the x and y index of the map,
+// which are multiplied by the multipliers and moved by the offsets to get
+// its position.
+type WorldPattern struct {
+	Regexp      string `json:"regexp"`
+	MultiplierX int    `json:"multiplierX"`
+	MultiplierY int    `json:"multiplierY"`
+	OffsetX     int    `json:"offsetX"`
+	OffsetY     int    `json:"offsetY"`
+	MapWidth    int    `json:"mapWidth"`  // The width of the maps in pixels, defaults to MultiplierX.
+	MapHeight   int    `json:"mapHeight"` // The height of the maps in pixels, defaults to MultiplierY.
+}
+
+// LoadWorld parses a .world file and resolves its patterns against the
+// files in its directory. No maps are loaded, only the headers of the maps
+// without a size are read.
+func LoadWorld(path string) (*World, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read world")
+	}
+	w := &World{dir: filepath.Dir(path)}
+	err = json.Unmarshal(data, w)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode world")
+	}
+	for _, wm := range w.Maps {
+		wm.path = resolvePath(w.dir, wm.FileName)
+		if wm.Width == 0 && wm.Height == 0 {
+			wm.Width, wm.Height, err = readMapSize(wm.path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read world map '%s'", wm.FileName)
+			}
+		}
+	}
+	if len(w.Patterns) == 0 {
+		return w, nil
+	}
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list world directory")
+	}
+	for _, p := range w.Patterns {
+		maps, err := p.resolve(w.dir, files)
+		if err != nil {
+			return nil, err
+		}
+		w.Maps = append(w.Maps, maps...)
+	}
+	return w, nil
+}
+
+// readMapSize returns the size of a map in pixels from the <map> element of
+// the file, without reading the rest of it. The size of infinite maps is 0.
+func readMapSize(path string) (int, int, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer fp.Close()
+	d := xml.NewDecoder(fp)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "no map element")
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "map" {
+			return 0, 0, errors.Errorf("unexpected element <%s>", start.Name.Local)
+		}
+		size := make(map[string]int)
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "width", "height", "tilewidth", "tileheight", "infinite":
+				size[attr.Name.Local], err = strconv.Atoi(attr.Value)
+				if err != nil {
+					return 0, 0, errors.Wrapf(err, "invalid %s", attr.Name.Local)
+				}
+			}
+		}
+		if size["infinite"] != 0 {
+			return 0, 0, nil
+		}
+		return size["width"] * size["tilewidth"], size["height"] * size["tileheight"], nil
+	}
+}
+
+// resolve returns the maps of the files matching the pattern.
+func (p WorldPattern) resolve(dir string, files []os.FileInfo) ([]*WorldMap, error) {
+	re, err := regexp.Compile(p.Regexp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid world pattern '%s'", p.Regexp)
+	}
+	w, h := p.MapWidth, p.MapHeight
+	if w == 0 {
+		w = p.MultiplierX
+	}
+	if h == 0 {
+		h = p.MultiplierY
+	}
+	var maps []*WorldMap
+	for _, fi := range files {
+		match := re.FindStringSubmatch(fi.Name())
+		if fi.IsDir() || len(match) < 3 {
+			continue
+		}
+		x, errX := strconv.Atoi(match[1])
+		y, errY := strconv.Atoi(match[2])
+		if errX != nil || errY != nil {
+			continue
+		}
+		maps = append(maps, &WorldMap{
+			FileName: fi.Name(),
+			X:        x*p.MultiplierX + p.OffsetX,
+			Y:        y*p.MultiplierY + p.OffsetY,
+			Width:    w,
+			Height:   h,
+			path:     filepath.Join(dir, fi.Name()),
+		})
+	}
+	sort.Slice(maps, func(i, j int) bool { return maps[i].FileName < maps[j].FileName })
+	return maps, nil
+}
+
+// Path returns the path of the map file.
+func (wm *WorldMap) Path() string {
+	return wm.path
+}
+
+// Bounds returns the area covered by the map in world pixels. It is empty
+// for infinite maps without a size in the world file.
+func (wm *WorldMap) Bounds() image.Rectangle {
+	return image.Rect(wm.X, wm.Y, wm.X+wm.Width, wm.Y+wm.Height)
+}
+
+// Map returns the map, it is loaded on the first call. It is safe to call
+// from multiple goroutines. The map is loaded without holding the lock of
+// the WorldMap, so Bounds, Loaded and Unload never wait for a load.
+func (wm *WorldMap) Map() (*Map, error) {
+	wm.mu.Lock()
+	m := wm.m
+	wm.mu.Unlock()
+	if m != nil {
+		return m, nil
+	}
+	fp, err := os.Open(wm.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open world map '%s'", wm.FileName)
+	}
+	defer fp.Close()
+	m, err = Load(fp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to load world map '%s'", wm.FileName)
+	}
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	if wm.m == nil {
+		wm.m = m
+	}
+	return wm.m, nil
+}
+
+// Loaded returns true if the map has been loaded.
+func (wm *WorldMap) Loaded() bool {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	return wm.m != nil
+}
+
+// Unload drops the loaded map, it is loaded again by the next call to Map.
+func (wm *WorldMap) Unload() {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	wm.m = nil
+}
+
+// ToLocal converts world coordinates in pixels to coordinates within the map.
+func (wm *WorldMap) ToLocal(x, y float64) (float64, float64) {
+	return x - float64(wm.X), y - float64(wm.Y)
+}
+
+// ToWorld converts coordinates within the map to world coordinates.
+func (wm *WorldMap) ToWorld(x, y float64) (float64, float64) {
+	return x + float64(wm.X), y + float64(wm.Y)
+}
+
+// MapAt returns the map containing the world point (x, y), or nil if there
+// is none. Where maps overlap the last one, which Tiled draws on top, is
+// returned. No maps are loaded.
+func (w *World) MapAt(x, y float64) *WorldMap {
+	for i := len(w.Maps) - 1; i >= 0; i-- {
+		wm := w.Maps[i]
+		b := wm.Bounds()
+		if x >= float64(b.Min.X) && x < float64(b.Max.X) && y >= float64(b.Min.Y) && y < float64(b.Max.Y) {
+			return wm
+		}
+	}
+	return nil
+}
+
+// MapsIn returns the maps overlapping the rectangle r in world pixels.
+func (w *World) MapsIn(r image.Rectangle) []*WorldMap {
+	var maps []*WorldMap
+	for _, wm := range w.Maps {
+		if wm.Bounds().Overlaps(r) {
+			maps = append(maps, wm)
+		}
+	}
+	return maps
+}
+
+// MapsNear returns the maps whose area is at most radius pixels away from the
+// world point (x, y).
+func (w *World) MapsNear(x, y, radius float64) []*WorldMap {
+	area := image.Rect(
+		int(math.Floor(x-radius)), int(math.Floor(y-radius)),
+		int(math.Ceil(x+radius))+1, int(math.Ceil(y+radius))+1,
+	)
+	var maps []*WorldMap
+	for _, wm := range w.MapsIn(area) {
+		b := wm.Bounds()
+		dx := math.Max(math.Max(float64(b.Min.X)-x, x-float64(b.Max.X)), 0)
+		dy := math.Max(math.Max(float64(b.Min.Y)-y, y-float64(b.Max.Y)), 0)
+		if dx*dx+dy*dy <= radius*radius {
+			maps = append(maps, wm)
+		}
+	}
+	return maps
+}
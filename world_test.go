@@ -0,0 +1,80 @@
+package tmx
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadWorld(t *testing.T) {
+	w, err := LoadWorld("resources/overworld.world")
+	require.NoError(t, err)
+	require.Len(t, w.Maps, 2)
+	cave, embedded := w.Maps[0], w.Maps[1]
+	assert.Equal(t, filepath.Join("resources", "cave.tmx"), cave.Path())
+	assert.Equal(t, image.Rect(0, 0, 480, 480), cave.Bounds())
+
+	// maps are found without loading them
+	assert.Equal(t, embedded, w.MapAt(490, -10))
+	assert.Equal(t, cave, w.MapAt(100, 200))
+	assert.Nil(t, w.MapAt(490, 10))
+	assert.False(t, cave.Loaded())
+
+	x, y := embedded.ToLocal(490, -10)
+	assert.Equal(t, []float64{10, 22}, []float64{x, y})
+	x, y = embedded.ToWorld(x, y)
+	assert.Equal(t, []float64{490, -10}, []float64{x, y})
+
+	assert.Equal(t, []*WorldMap{cave, embedded}, w.MapsIn(image.Rect(470, -5, 500, 5)))
+	assert.Equal(t, []*WorldMap{embedded}, w.MapsNear(500, 10, 15))
+	assert.Equal(t, []*WorldMap{cave, embedded}, w.MapsNear(500, 10, 25))
+	assert.False(t, cave.Loaded())
+
+	m, err := cave.Map()
+	require.NoError(t, err)
+	assert.EqualValues(t, 30, m.Width)
+	cave.Unload()
+	assert.False(t, cave.Loaded())
+
+	// infinite maps have no size in their header
+	width, height, err := readMapSize("resources/modern.tmx")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 0}, []int{width, height})
+}
+
+func TestWorldPatterns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tmx-world")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"room-0-0.tmx", "room-1-0.tmx", "room-2-3.tmx", "room-x-1.tmx", "other.tmx"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	world := `{
+		"patterns": [{
+			"regexp": "room-(\\d+)-(\\d+)\\.tmx",
+			"multiplierX": 320,
+			"multiplierY": 240,
+			"offsetX": -320,
+			"offsetY": 0
+		}],
+		"type": "world"
+	}`
+	path := filepath.Join(dir, "rooms.world")
+	require.NoError(t, ioutil.WriteFile(path, []byte(world), 0644))
+
+	w, err := LoadWorld(path)
+	require.NoError(t, err)
+	require.Len(t, w.Maps, 3)
+	assert.Equal(t, "room-0-0.tmx", w.Maps[0].FileName)
+	assert.Equal(t, image.Rect(-320, 0, 0, 240), w.Maps[0].Bounds())
+	assert.Equal(t, image.Rect(320, 720, 640, 960), w.Maps[2].Bounds())
+	assert.Equal(t, w.Maps[1], w.MapAt(10, 10))
+
+	_, err = LoadWorld(filepath.Join(dir, "missing.world"))
+	assert.Error(t, err)
+}